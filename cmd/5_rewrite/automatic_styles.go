@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// Paragraphs rarely refer to a named style directly. Any direct formatting,
+// even an rsid LibreOffice adds while text is typed, gets the paragraph an
+// automatic style (P12) whose style:parent-style-name is the named style.
+// Renaming a style rewrites that parent like any other reference; the
+// functions here count what is reached that way, and optionally fold
+// automatic styles that add nothing into the renamed parent.
+
+// partPrefix returns the sub-document a part belongs to, "" or "Object 1/"
+func partPrefix(name string) string {
+	return strings.TrimSuffix(name, path.Base(name))
+}
+
+// automaticParents maps each automatic style of a part whose parent is
+// being renamed to the mapping that renames the parent
+func (sr *StyleRenamer) automaticParents(styles *odf.Styles, part string) map[odf.StyleKey]StyleMapping {
+	parents := make(map[odf.StyleKey]StyleMapping)
+	for _, def := range styles.Automatic(part) {
+		parent := styles.Parent(def)
+		if parent == nil {
+			continue
+		}
+		if mapping, ok := sr.mappingFor(parent.Key.Family, parent.Key.Name); ok {
+			parents[def.Key] = mapping
+		}
+	}
+	return parents
+}
+
+// foldAutomaticStyles points references to redundant automatic styles,
+// whose parent is being renamed, straight at the parent, and deletes their
+// definitions. It returns the names of the styles folded.
+func (sr *StyleRenamer) foldAutomaticStyles(root *xmltree.Node, styles *odf.Styles, part string) []string {
+	folded := make(map[odf.StyleKey]string)
+	for key := range sr.automaticParents(styles, part) {
+		def := styles.Lookup(part, key)
+		if styles.Redundant(def) {
+			folded[key] = def.ParentName()
+		}
+	}
+	if len(folded) == 0 {
+		return nil
+	}
+
+	var names []string
+	root.Walk(func(n *xmltree.Node) bool {
+		if n.Kind != xmltree.ElementNode {
+			return false
+		}
+		for i, attr := range n.Attrs {
+			ref, ok := odf.LookupStyleRef(n, attr.Name)
+			if !ok {
+				continue
+			}
+			names := ref.StyleNames(attr.Value)
+			for j, name := range names {
+				if parent, ok := folded[odf.StyleKey{Family: ref.Family, Name: name}]; ok {
+					names[j] = parent
+				}
+			}
+			if ref.Defines && n.Parent.Is(odf.NSOffice, "automatic-styles") {
+				if _, ok := folded[odf.StyleKey{Family: ref.Family, Name: attr.Value}]; ok {
+					n.Remove()
+					return false
+				}
+				continue
+			}
+			n.Attrs[i].Value = strings.Join(names, " ")
+		}
+		return true
+	})
+
+	for key := range folded {
+		names = append(names, key.Name)
+	}
+	sortStyleNames(names)
+	return names
+}
+
+// sortStyleNames sorts automatic style names the way LibreOffice numbers
+// them, so that P2 comes before P10
+func sortStyleNames(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestFoldAutomaticStyles(t *testing.T) {
+	const namespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+		` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"` +
+		` xmlns:officeooo="http://openoffice.org/2009/office"`
+	const styles = `<office:document-styles ` + namespaces + `><office:styles>` +
+		`<style:style style:name="Preformatted_20_Text" style:display-name="Preformatted Text" style:family="paragraph"><style:paragraph-properties fo:margin-top="0in"/></style:style>` +
+		`<style:style style:name="Quotations" style:family="paragraph"/>` +
+		`</office:styles></office:document-styles>`
+	const content = `<office:document-content ` + namespaces + `><office:automatic-styles>` +
+		// Only an rsid, so redundant
+		`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Preformatted_20_Text"><style:paragraph-properties officeooo:paragraph-rsid="00aa"/></style:style>` +
+		// Repeats the parent, so redundant
+		`<style:style style:name="P10" style:family="paragraph" style:parent-style-name="Preformatted_20_Text"><style:paragraph-properties fo:margin-top="0in"/></style:style>` +
+		// Real formatting
+		`<style:style style:name="P2" style:family="paragraph" style:parent-style-name="Preformatted_20_Text"><style:paragraph-properties fo:margin-top="1in"/></style:style>` +
+		// Redundant, but its parent isn't renamed
+		`<style:style style:name="P3" style:family="paragraph" style:parent-style-name="Quotations"/>` +
+		`</office:automatic-styles><office:body><office:text>` +
+		`<text:p text:style-name="P1">a</text:p><text:p text:style-name="P10">b</text:p>` +
+		`<text:p text:style-name="P2">c</text:p><text:p text:style-name="P3">d</text:p>` +
+		`</office:text></office:body></office:document-content>`
+
+	parts := make(map[string]*xmltree.Document)
+	for name, data := range map[string]string{"styles.xml": styles, "content.xml": content} {
+		doc, err := xmltree.Parse([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[name] = doc
+	}
+	sr := &StyleRenamer{Mappings: []StyleMapping{{Family: "paragraph", OldStyleName: "Preformatted_20_Text", NewStyleName: "Code"}}}
+
+	styleIndex := odf.NewStyles(parts)
+	parents := sr.automaticParents(styleIndex, "content.xml")
+	if len(parents) != 3 {
+		t.Errorf("automatic styles based on the renamed style = %v, want P1, P10 and P2", parents)
+	}
+
+	folded := sr.foldAutomaticStyles(parts["content.xml"].Root, styleIndex, "content.xml")
+	if want := []string{"P1", "P10"}; !reflect.DeepEqual(folded, want) {
+		t.Errorf("folded %q, want %q", folded, want)
+	}
+
+	data, err := parts["content.xml"].Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`<text:p text:style-name="Preformatted_20_Text">a</text:p><text:p text:style-name="Preformatted_20_Text">b</text:p>`,
+		`<text:p text:style-name="P2">c</text:p><text:p text:style-name="P3">d</text:p>`,
+		`style:name="P2"`,
+		`style:name="P3"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("content.xml lacks %s:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{`style:name="P1"`, `style:name="P10"`} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("content.xml still defines %s", unwanted)
+		}
+	}
+}
+
+func TestSortStyleNames(t *testing.T) {
+	names := []string{"P10", "P2", "T1", "P1", "Mpm3"}
+	sortStyleNames(names)
+	if want := []string{"P1", "P2", "T1", "P10", "Mpm3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sortStyleNames = %q, want %q", names, want)
+	}
+}
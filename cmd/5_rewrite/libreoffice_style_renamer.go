@@ -4,17 +4,22 @@ package main
 This Go algorithm provides a comprehensive solution for renaming LibreOffice styles. Here's how it works:
 Key Components:
 
-Custom XML Parser: Uses a flexible Node struct that preserves the exact XML structure while allowing modifications to attributes.
-Style Reference Detection: The isStyleAttribute function identifies common LibreOffice style attributes like style-name, parent-style-name, etc.
+Custom XML Parser: Uses the xmltree package, which keeps text, elements, comments and processing instructions interleaved in order while allowing modifications to attributes.
+Style Reference Detection: The odf package's style reference registry says which attributes on which elements name a style, and of which family, so a paragraph-style rename never touches a same-named graphic, table or text style.
+Automatic Styles: Paragraphs usually point at an automatic style (P12) based on the named style, so renaming the named style rewrites each automatic style's parent, and --fold-automatic replaces the ones that only add rsids or inherited values with the renamed style itself.
 Recursive Updates: Traverses the entire XML tree to find and update all references to the old style name.
-Multiple File Support: Can process individual XML files or handle the complete set of files from an extracted ODT document.
+Multiple File Support: Can process individual XML files or every XML part of an ODT archive, in memory.
 
 Usage Process:
-For an ODT file, you would:
+For an ODT file, a single command renames the style in styles.xml, content.xml, meta.xml,
+settings.xml and any embedded objects, and writes a new archive:
 
-Extract the ODT (it's a ZIP archive)
-Run the renamer on the extracted contents
-Repackage the modified files back into an ODT
+    go run . rename chapter.odt Preformatted_20_Text Code chapter_renamed.odt
+
+Style names can also be typed the way the Styles sidebar shows them, and the display name
+of the renamed style is updated to match:
+
+    go run . rename chapter.odt "Preformatted Text" "Code Block"
 
 For just styles.xml:
 gorenamer := &StyleRenamer{
@@ -29,114 +34,185 @@ Would you like me to modify any part of this algorithm or add additional feature
 */
 
 import (
-	"encoding/xml"
 	"fmt"
-	"io"
 	"os"
+	"path/filepath"
 	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
 )
 
-// StyleRenamer handles renaming styles in LibreOffice documents
+// StyleRenamer handles renaming styles in LibreOffice documents. It renames
+// OldStyleName to NewStyleName, if set, and every style in Mappings, all in
+// one pass over each XML part. Names may be given in display form, such as
+// "Preformatted Text", as well as internal form, such as Preformatted_20_Text.
 type StyleRenamer struct {
-	OldStyleName string
-	NewStyleName string
+	OldStyleName  string
+	NewStyleName  string
+	Mappings      []StyleMapping
+	OnCollision   CollisionPolicy
+	FoldAutomatic bool                 // fold redundant automatic styles into renamed parents
+	StripRsids    bool                 // strip editing session ids before renaming
+	Counts        map[StyleMapping]int // references changed, per mapping
+	Indirect      map[StyleMapping]int // references to automatic styles based on a renamed style
+	Collisions    []Collision
+	Folded        []string
+
+	resolved  []StyleMapping
+	dropped   map[odf.StyleKey]bool         // definitions merged into an existing style
+	automatic map[odf.StyleKey]StyleMapping // automatic styles of the part being renamed
 }
 
-// Node represents a generic XML node with attributes and children
-type Node struct {
-	XMLName  xml.Name
-	Attrs    []xml.Attr `xml:",any,attr"`
-	Content  []byte     `xml:",innerxml"`
-	Children []Node
+// mappings returns every rename the renamer applies
+func (sr *StyleRenamer) mappings() []StyleMapping {
+	if sr.resolved != nil {
+		return sr.resolved
+	}
+	if sr.OldStyleName == "" {
+		return sr.Mappings
+	}
+	single := StyleMapping{OldStyleName: sr.OldStyleName, NewStyleName: sr.NewStyleName}
+	return append([]StyleMapping{single}, sr.Mappings...)
 }
 
-// UnmarshalXML implements custom unmarshaling to preserve structure
-func (n *Node) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	n.XMLName = start.Name
-	n.Attrs = start.Attr
-
-	var content strings.Builder
-	for {
-		token, err := d.Token()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-
-		switch t := token.(type) {
-		case xml.StartElement:
-			// Found a child element, parse it recursively
-			var child Node
-			if err := child.UnmarshalXML(d, t); err != nil {
-				return err
+// resolveNames turns the names the user typed into internal style names,
+// matching old names against the display names of the styles defined in
+// roots, and works out the display name each renamed style should carry.
+// It fails if a new name is already taken and the policy is CollisionFail.
+func (sr *StyleRenamer) resolveNames(roots ...*xmltree.Node) error {
+	defs := make(map[odf.StyleKey]*xmltree.Node)
+	for _, root := range roots {
+		root.Walk(func(n *xmltree.Node) bool {
+			for _, attr := range n.Attrs {
+				if ref, ok := odf.LookupStyleRef(n, attr.Name); ok && ref.Defines {
+					defs[odf.StyleKey{Family: ref.Family, Name: attr.Value}] = n
+				}
 			}
-			n.Children = append(n.Children, child)
-
-		case xml.EndElement:
-			// End of current element
-			n.Content = []byte(content.String())
-			return nil
+			return n.Kind == xmltree.ElementNode
+		})
+	}
 
-		case xml.CharData:
-			// Text content
-			content.Write(t)
+	resolved := []StyleMapping{}
+	for _, mapping := range sr.mappings() {
+		mapping.OldStyleName = resolveOldName(defs, mapping.Family, mapping.OldStyleName)
+		if odf.NeedsEncoding(mapping.NewStyleName) {
+			mapping.NewDisplayName = mapping.NewStyleName
+			mapping.NewStyleName = odf.EncodeStyleName(mapping.NewStyleName)
+		} else {
+			mapping.NewDisplayName = odf.DisplayNameFor(mapping.NewStyleName)
 		}
+		resolved = append(resolved, mapping)
 	}
 
-	n.Content = []byte(content.String())
+	resolved, err := sr.avoidCollisions(defs, resolved)
+	if err != nil {
+		return err
+	}
+	sr.resolved = resolved
 	return nil
 }
 
-// MarshalXML implements custom marshaling to reconstruct XML
-func (n Node) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	start.Name = n.XMLName
-	start.Attr = n.Attrs
-
-	if err := e.EncodeToken(start); err != nil {
-		return err
+// resolveOldName finds the internal name of the style the user meant: a
+// defined internal name, then a defined display name, then the encoded form
+func resolveOldName(defs map[odf.StyleKey]*xmltree.Node, family, name string) string {
+	for key := range defs {
+		if key.Name == name && (family == "" || key.Family == family) {
+			return name
+		}
 	}
-
-	// Write text content if no children
-	if len(n.Children) == 0 && len(n.Content) > 0 {
-		if err := e.EncodeToken(xml.CharData(n.Content)); err != nil {
-			return err
+	for key, definition := range defs {
+		if odf.DisplayName(definition) == name && (family == "" || key.Family == family) {
+			return key.Name
 		}
 	}
+	if odf.NeedsEncoding(name) {
+		return odf.EncodeStyleName(name)
+	}
+	return name
+}
 
-	// Encode children
-	for _, child := range n.Children {
-		if err := e.Encode(child); err != nil {
-			return err
+// mappingFor finds the rename for a style name of the given family
+func (sr *StyleRenamer) mappingFor(family, name string) (StyleMapping, bool) {
+	for _, mapping := range sr.mappings() {
+		if mapping.OldStyleName != name {
+			continue
+		}
+		if mapping.Family == "" || mapping.Family == family {
+			return mapping, true
 		}
 	}
+	return StyleMapping{}, false
+}
 
-	return e.EncodeToken(xml.EndElement{Name: n.XMLName})
+// notFound says that none of the styles being renamed were found, for
+// messages
+func (sr *StyleRenamer) notFound() string {
+	if sr.OldStyleName != "" && len(sr.Mappings) == 0 {
+		return fmt.Sprintf("style '%s' not found", sr.OldStyleName)
+	}
+	return fmt.Sprintf("none of the %d mapped styles were found", len(sr.mappings()))
 }
 
 // updateStyleReferences updates all style references in the XML tree
-func (sr *StyleRenamer) updateStyleReferences(node *Node) bool {
+func (sr *StyleRenamer) updateStyleReferences(node *xmltree.Node) bool {
 	modified := false
+	if sr.Counts == nil {
+		sr.Counts = make(map[StyleMapping]int)
+		sr.Indirect = make(map[StyleMapping]int)
+	}
 
-	// Update attributes that reference styles
+	// Update attributes that reference styles, leaving anything that only
+	// looks like one (table:name, draw:name, bookmarks...) alone
+	var definedBy *StyleMapping
+	var definingSpace string
 	for i, attr := range node.Attrs {
-		// if the attr is Name, update it
-		if sr.isStyleAttribute(attr.Name.Local) && attr.Value == sr.OldStyleName {
-			node.Attrs[i].Value = sr.NewStyleName
-			modified = true
+		ref, ok := odf.LookupStyleRef(node, attr.Name)
+		if !ok {
+			continue
 		}
-		// FIXME, find display-name and update it too
-		if sr.isStyleAttribute(attr.Name.Local) && attr.Value == sr.OldStyleName {
-			node.Attrs[i].Value = sr.NewStyleName
-			modified = true
+
+		names := ref.StyleNames(attr.Value)
+		changed := false
+		for j, name := range names {
+			mapping, ok := sr.mappingFor(ref.Family, name)
+			if !ok {
+				// A P12 whose parent is renamed counts as a use of the parent
+				if via, ok := sr.automatic[odf.StyleKey{Family: ref.Family, Name: name}]; ok && !ref.Defines {
+					sr.Indirect[via]++
+				}
+				continue
+			}
+			names[j] = mapping.NewStyleName
+			changed = true
+
+			// Only uses count, not the style's own definition
+			if !ref.Defines {
+				sr.Counts[mapping]++
+				continue
+			}
+			definedBy, definingSpace = &mapping, attr.Name.Space
+			if sr.dropped[odf.StyleKey{Family: ref.Family, Name: name}] {
+				// Merged into an existing style of the new name
+				node.Remove()
+				return true
+			}
+		}
+		if !changed {
+			continue
 		}
+		node.Attrs[i].Value = strings.Join(names, " ")
+		modified = true
+	}
 
+	// The definition carries the name shown in the Styles sidebar
+	if definedBy != nil {
+		setDisplayName(node, definingSpace, definedBy.NewDisplayName)
 	}
 
-	// Recursively update children
-	for i := range node.Children {
-		if sr.updateStyleReferences(&node.Children[i]) {
+	// Recursively update child elements
+	for _, child := range node.Elements() {
+		if sr.updateStyleReferences(child) {
 			modified = true
 		}
 	}
@@ -144,133 +220,289 @@ func (sr *StyleRenamer) updateStyleReferences(node *Node) bool {
 	return modified
 }
 
-// isStyleAttribute checks if an attribute name typically references a style // FIXME dig into this
-func (sr *StyleRenamer) isStyleAttribute(attrName string) bool {
-	styleAttributes := []string{
-		"style-name",
-		"parent-style-name",
-		"next-style-name",
-		"master-page-name",
-		"page-layout-name",
-		"name", // for style definitions themselves
-	}
-
-	for _, styleAttr := range styleAttributes {
-		if attrName == styleAttr {
-			return true
-		}
+// setDisplayName sets or, when the internal name reads well enough by
+// itself, removes the display name of a style definition. It lives in the
+// same namespace as the name (style:display-name, draw:display-name).
+func setDisplayName(definition *xmltree.Node, space, display string) {
+	if display == "" {
+		definition.RemoveAttr(space, "display-name")
+		return
 	}
-	return false
+	definition.SetAttr(space, "display-name", display)
 }
 
 // RenameStyleInFile processes a single LibreOffice XML file
-// FIXME does not change display name
 func (sr *StyleRenamer) RenameStyleInFile(inputPath, outputPath string) error {
 	// Read the XML file
-	file, err := os.Open(inputPath)
+	data, err := os.ReadFile(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
 
-	// Parse XML
-	var root Node
-	decoder := xml.NewDecoder(file)
-	if err := decoder.Decode(&root); err != nil {
-		return fmt.Errorf("failed to parse XML: %w", err)
+	// Parse XML, keeping text, comments and elements in order
+	doc, err := xmltree.Parse(data)
+	if err != nil {
+		return err
 	}
 
 	// Update style references
-	modified := sr.updateStyleReferences(&root)
-
-	if !modified {
-		return fmt.Errorf("style '%s' not found in file, exiting without making changes", sr.OldStyleName)
+	if err := sr.resolveNames(doc.Root); err != nil {
+		return err
 	}
-
-	// Write modified XML
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+	if !sr.updateStyleReferences(doc.Root) {
+		return fmt.Errorf("%s in file, exiting without making changes", sr.notFound())
 	}
-	defer outputFile.Close()
 
-	// Write XML declaration
-	if _, err := outputFile.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n"); err != nil {
-		return fmt.Errorf("failed to write XML declaration: %w", err)
+	// Encode the modified XML, including its declaration
+	modified, err := doc.Bytes()
+	if err != nil {
+		return err
 	}
 
-	// Encode the modified XML
-	encoder := xml.NewEncoder(outputFile)
-	encoder.Indent("", "  ")
-	if err := encoder.Encode(&root); err != nil {
-		return fmt.Errorf("failed to encode XML: %w", err)
+	// Write modified XML
+	if err := os.WriteFile(outputPath, modified, 0644); err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
 
 	return nil
 }
 
-// RenameStyleInODT processes a complete ODT file (which is a ZIP archive)
-func (sr *StyleRenamer) RenameStyleInODT(odtPath string) error {
-	// For a complete ODT implementation, you would:
-	// 1. Extract the ODT file (it's a ZIP archive)
-	// 2. Process styles.xml, content.xml, and possibly other XML files
-	// 3. Repackage into a new ODT file
-
-	// This is a simplified version that assumes you've already extracted the ODT
-	// and want to process individual XML files
+// RenameStyleInODT renames the style in every XML part of an ODT file,
+// including settings.xml and embedded "Object N/" sub-documents, and writes
+// the result to outputPath. Entries it doesn't change are copied through
+// byte for byte.
+func (sr *StyleRenamer) RenameStyleInODT(odtPath, outputPath string) error {
+	doc, err := odf.OpenDocument(odtPath)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
 
-	xmlFiles := []string{
-		"styles.xml",
-		"content.xml",
-		"meta.xml",
+	if sr.StripRsids {
+		if err := stripRsids(doc); err != nil {
+			return err
+		}
 	}
 
-	for _, xmlFile := range xmlFiles {
-		inputPath := xmlFile
-		outputPath := xmlFile + ".new"
+	// Names typed by the user are resolved against the document's own styles
+	var roots []*xmltree.Node
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		part, err := doc.Part(name)
+		if err != nil {
+			return fmt.Errorf("failed to process %s: %w", name, err)
+		}
+		if part != nil {
+			roots = append(roots, part.Root)
+		}
+	}
+	if err := sr.resolveNames(roots...); err != nil {
+		return err
+	}
 
-		if _, err := os.Stat(inputPath); os.IsNotExist(err) {
-			// File doesn't exist, skip it
-			continue
+	updated := 0
+	styles := make(map[string]*odf.Styles)
+	for _, entry := range doc.Package.DocumentParts() {
+		fmt.Printf("Processing %s...\n", entry.Name)
+		part, err := doc.Part(entry.Name)
+		if err != nil {
+			return fmt.Errorf("failed to process %s: %w", entry.Name, err)
 		}
 
-		fmt.Printf("Processing %s...\n", xmlFile)
-		if err := sr.RenameStyleInFile(inputPath, outputPath); err != nil {
-			// If style not found, that's okay for some files
-			if strings.Contains(err.Error(), "not found") {
-				fmt.Printf("Style '%s' not found in %s (this may be normal)\n", sr.OldStyleName, xmlFile)
-				continue
+		// Follow automatic styles to the named styles they are based on
+		prefix := partPrefix(entry.Name)
+		if styles[prefix] == nil {
+			if styles[prefix], err = doc.Styles(prefix); err != nil {
+				return fmt.Errorf("failed to process %s: %w", entry.Name, err)
+			}
+		}
+		folded := 0
+		if sr.FoldAutomatic {
+			names := sr.foldAutomaticStyles(part.Root, styles[prefix], entry.Name)
+			for _, name := range names {
+				sr.Folded = append(sr.Folded, entry.Name+": "+name)
 			}
-			return fmt.Errorf("failed to process %s: %w", xmlFile, err)
+			folded = len(names)
 		}
+		sr.automatic = sr.automaticParents(styles[prefix], entry.Name)
 
-		// Replace original with modified version
-		if err := os.Rename(outputPath, inputPath); err != nil {
-			return fmt.Errorf("failed to replace %s: %w", xmlFile, err)
+		if !sr.updateStyleReferences(part.Root) && folded == 0 {
+			// If style not found, that's okay for some files
+			fmt.Printf("No renamed styles found in %s (this may be normal)\n", entry.Name)
+			continue
 		}
 
-		fmt.Printf("Successfully updated %s\n", xmlFile)
+		updated++
+		fmt.Printf("Successfully updated %s\n", entry.Name)
 	}
 
-	return nil
+	if updated == 0 {
+		return fmt.Errorf("%s in %s, exiting without making changes", sr.notFound(), odtPath)
+	}
+
+	return doc.Save(outputPath)
+}
+
+// renamedFilename creates the output filename by adding _renamed before .odt
+func renamedFilename(inputPath string) string {
+	ext := filepath.Ext(inputPath)
+	return strings.TrimSuffix(inputPath, ext) + "_renamed" + ext
+}
+
+func usage() {
+	fmt.Printf("Usage: %s [command]\n", os.Args[0])
+	fmt.Println("  unpack <doc.odt> <dir>: extract a document into a directory")
+	fmt.Println("  pack <dir> <doc.odt>: build a document from an extracted directory")
+	fmt.Println("  rename <doc.odt> <old-style> <new-style> [out.odt]: rename a style (default output: doc_renamed.odt)")
+	fmt.Println("  rename-map <doc.odt> <stylemap.txt> [out.odt]: rename every style listed in a mapping file")
+	fmt.Println("    --collisions=fail|merge|suffix: when a new name is taken, stop (default), merge into")
+	fmt.Println("    the existing style, or use the next free \"Name 2\", \"Name 3\"...")
+	fmt.Println("    --fold-automatic: replace automatic styles (P1, T1...) that only add rsids or inherited")
+	fmt.Println("    values to a renamed style with the renamed style itself")
+	fmt.Println("    --strip-rsids: strip editing session ids first, as the strip-rsids command does")
+	fmt.Println("  import-styles <doc.odt> <template.ott> [out.odt]: copy the styles the document refers to")
+	fmt.Println("    but doesn't define from a template, with the styles they depend on (default: in place)")
+	fmt.Println("    --all: import every common style and master page of the template instead")
+	fmt.Println("    --existing=keep|overwrite|report: leave styles the document defines differently alone")
+	fmt.Println("    (default), replace them with the template's, or leave them and show both definitions")
+	fmt.Println("    --dry-run: only report what would be imported, writing nothing")
+	fmt.Println("  strip-rsids <doc.odt> [out.odt]: remove LibreOffice's editing session ids, the automatic")
+	fmt.Println("    styles left empty and the spans that only carried them (default: in place)")
+	fmt.Println("  gc-styles <doc.odt> [out.odt]: remove automatic styles nothing refers to (default: in place)")
+	fmt.Println("    --common: remove unused paragraph, text, list, table, table cell, section and graphic")
+	fmt.Println("    (frame) common styles as well")
+	fmt.Println("    --keep=<file>: common styles to keep even when unused, one name per line")
+	fmt.Println("  check <doc.odt>: report style references with no matching definition")
+	fmt.Println("  styles <doc.odt>: list the styles defined in a document and how often each is used")
+	fmt.Println("  [part.xml]: with no command, renames Preformatted_20_Text to Code in an XML part")
+	fmt.Println("    (default: styles.xml), writing part_modified.xml")
+	os.Exit(1)
+}
+
+// parseOptions takes the options out of args: --collisions=<policy>,
+// --fold-automatic and --strip-rsids for the renamer, --existing=<policy>,
+// --all and --dry-run for import-styles, and --common and --keep=<file>
+// for gc-styles
+func parseOptions(args []string, renamer *StyleRenamer, imports *ImportOptions, cleanup *CleanupOptions) ([]string, error) {
+	var rest []string
+	renamer.OnCollision = CollisionFail
+	imports.Existing = odf.ImportKeep
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "--collisions="):
+			policy, err := parseCollisionPolicy(strings.TrimPrefix(arg, "--collisions="))
+			if err != nil {
+				return nil, err
+			}
+			renamer.OnCollision = policy
+		case arg == "--fold-automatic":
+			renamer.FoldAutomatic = true
+		case arg == "--strip-rsids":
+			renamer.StripRsids = true
+		case strings.HasPrefix(arg, "--existing="):
+			policy, err := parseImportPolicy(strings.TrimPrefix(arg, "--existing="))
+			if err != nil {
+				return nil, err
+			}
+			imports.Existing = policy
+		case arg == "--all":
+			imports.All = true
+		case arg == "--dry-run":
+			imports.DryRun = true
+		case arg == "--common":
+			cleanup.Common = true
+		case strings.HasPrefix(arg, "--keep="):
+			cleanup.KeepFile = strings.TrimPrefix(arg, "--keep=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, nil
 }
 
-// Example usage
 func main() {
-	// Create a style renamer
-	renamer := &StyleRenamer{
-		OldStyleName: "Preformatted_20_Text",
-		NewStyleName: "Code",
+	renamer := &StyleRenamer{}
+	imports := ImportOptions{}
+	cleanup := CleanupOptions{}
+	args, err := parseOptions(os.Args, renamer, &imports, &cleanup)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// A lone XML part is renamed as in the example below
+	if len(args) > 1 && !(len(args) == 2 && filepath.Ext(args[1]) == ".xml") {
+		switch {
+		case args[1] == "unpack" && len(args) == 4:
+			err = unpackODT(args[2], args[3])
+		case args[1] == "pack" && len(args) == 4:
+			err = packODT(args[2], args[3])
+		case args[1] == "rename" && (len(args) == 5 || len(args) == 6):
+			outputPath := renamedFilename(args[2])
+			if len(args) == 6 {
+				outputPath = args[5]
+			}
+			renamer.OldStyleName, renamer.NewStyleName = args[3], args[4]
+			if err = renamer.RenameStyleInODT(args[2], outputPath); err == nil {
+				renamer.PrintReport()
+			}
+		case args[1] == "rename-map" && (len(args) == 4 || len(args) == 5):
+			outputPath := renamedFilename(args[2])
+			if len(args) == 5 {
+				outputPath = args[4]
+			}
+			if err = renamer.LoadStyleMappings(args[3]); err == nil {
+				if err = renamer.RenameStyleInODT(args[2], outputPath); err == nil {
+					renamer.PrintReport()
+				}
+			}
+		case args[1] == "import-styles" && (len(args) == 4 || len(args) == 5):
+			outputPath := args[2]
+			if len(args) == 5 {
+				outputPath = args[4]
+			}
+			err = importStyles(args[2], args[3], outputPath, imports)
+		case args[1] == "strip-rsids" && (len(args) == 3 || len(args) == 4):
+			outputPath := args[2]
+			if len(args) == 4 {
+				outputPath = args[3]
+			}
+			err = stripRsidsInODT(args[2], outputPath)
+		case args[1] == "gc-styles" && (len(args) == 3 || len(args) == 4):
+			outputPath := args[2]
+			if len(args) == 4 {
+				outputPath = args[3]
+			}
+			err = removeUnusedStyles(args[2], outputPath, cleanup)
+		case args[1] == "check" && len(args) == 3:
+			err = checkODT(args[2])
+		case args[1] == "styles" && len(args) == 3:
+			err = listStyles(args[2])
+		default:
+			usage()
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
+	// Create a style renamer
+	renamer.OldStyleName = "Preformatted_20_Text"
+	renamer.NewStyleName = "Code"
+
 	// Example 1: Process a single XML file
-	if err := renamer.RenameStyleInFile("styles.xml", "styles_modified.xml"); err != nil {
+	inputPath := "styles.xml"
+	if len(args) == 2 {
+		inputPath = args[1]
+	}
+	outputPath := strings.TrimSuffix(inputPath, ".xml") + "_modified.xml"
+	if err := renamer.RenameStyleInFile(inputPath, outputPath); err != nil {
 		fmt.Printf("Error processing single file: %v\n", err)
 	}
 
-	//// Example 2: Process extracted ODT contents
-	//// (First extract your ODT file to the current directory)
-	//if err := renamer.RenameStyleInODT("."); err != nil {
+	//// Example 2: Process a complete ODT file
+	//if err := renamer.RenameStyleInODT("example.odt", "example2.odt"); err != nil {
 	//	fmt.Printf("Error processing ODT contents: %v\n", err)
 	//}
 
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestRenameStyleInFileNotFound(t *testing.T) {
+	const styles = `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<office:document-styles xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"><office:styles>` +
+		`<style:style style:name="Text_20_body" style:display-name="Text body" style:family="paragraph"/>` +
+		`</office:styles></office:document-styles>`
+
+	tests := []struct {
+		name    string
+		renamer StyleRenamer
+		want    string
+	}{
+		{
+			"single style",
+			StyleRenamer{OldStyleName: "Preformatted_20_Text", NewStyleName: "Code"},
+			"style 'Preformatted_20_Text' not found in file, exiting without making changes",
+		},
+		{
+			"mapping file",
+			StyleRenamer{Mappings: []StyleMapping{
+				{OldStyleName: "Preformatted_20_Text", NewStyleName: "Code"},
+				{OldStyleName: "Quotations", NewStyleName: "Quote"},
+			}},
+			"none of the 2 mapped styles were found in file, exiting without making changes",
+		},
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "styles.xml")
+	if err := os.WriteFile(input, []byte(styles), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(dir, "out.xml")
+			err := tt.renamer.RenameStyleInFile(input, output)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+			if _, err := os.Stat(output); !os.IsNotExist(err) {
+				t.Errorf("output written despite the error")
+			}
+		})
+	}
+}
+
+func TestResolveOldName(t *testing.T) {
+	const styles = `<office:document-styles xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+		` xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0"` +
+		` xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"><office:styles>` +
+		`<style:style style:name="Text_20_body" style:display-name="Text body" style:family="paragraph"/>` +
+		`<style:style style:name="Quote" style:display-name="Zitat" style:family="paragraph"/>` +
+		`<style:style style:name="Zitat" style:family="text"/>` +
+		`<text:list-style style:name="Numbering_20_123" style:display-name="Numbering 123"/>` +
+		`<text:outline-style style:name="Outline" style:display-name="Chapter Numbering"/>` +
+		`<number:date-style style:name="N37" style:display-name="Long Date"/>` +
+		`<draw:gradient draw:name="Gradient_20_1" draw:display-name="Sunrise"/>` +
+		`</office:styles></office:document-styles>`
+	doc, err := xmltree.Parse([]byte(styles))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		family string
+		name   string
+		want   string
+	}{
+		{"", "Text_20_body", "Text_20_body"},
+		{"", "Text body", "Text_20_body"},
+		{"paragraph", "Text body", "Text_20_body"},
+		{"list", "Numbering 123", "Numbering_20_123"},
+		{"", "Numbering 123", "Numbering_20_123"},
+		{"list", "Chapter Numbering", "Outline"},
+		{"data", "Long Date", "N37"},
+		{"gradient", "Sunrise", "Gradient_20_1"},
+		// A defined internal name wins over another style's display name
+		{"", "Zitat", "Zitat"},
+		{"paragraph", "Zitat", "Quote"},
+		// Undefined names are taken as display names if they have to be
+		{"", "Not Defined", "Not_20_Defined"},
+		{"", "NotDefined", "NotDefined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.family+" "+tt.name, func(t *testing.T) {
+			sr := &StyleRenamer{
+				Mappings:    []StyleMapping{{OldStyleName: tt.name, NewStyleName: "Renamed", Family: tt.family}},
+				OnCollision: CollisionFail,
+			}
+			if err := sr.resolveNames(doc.Root); err != nil {
+				t.Fatalf("resolveNames: %v", err)
+			}
+			if got := sr.mappings()[0].OldStyleName; got != tt.want {
+				t.Errorf("resolved %q to %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
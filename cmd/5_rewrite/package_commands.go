@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// unpackODT extracts a document into dir, restoring entry timestamps
+func unpackODT(odtPath, dir string) error {
+	pkg, err := odf.Open(odtPath)
+	if err != nil {
+		return err
+	}
+	defer pkg.Close()
+
+	if err := pkg.Extract(dir); err != nil {
+		return fmt.Errorf("failed to unpack %s: %w", odtPath, err)
+	}
+	fmt.Printf("Unpacked %d entries from %s into %s\n", len(pkg.Entries), odtPath, dir)
+	return nil
+}
+
+// packODT builds a document from an unpacked directory, repairing the
+// manifest if files were added or removed by hand
+func packODT(dir, odtPath string) error {
+	pkg, err := odf.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	changes, err := pkg.FixManifest()
+	if err != nil {
+		return fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	for _, change := range changes {
+		fmt.Printf("Manifest: %s\n", change)
+	}
+
+	if err := pkg.WriteFile(odtPath); err != nil {
+		return fmt.Errorf("failed to pack %s: %w", dir, err)
+	}
+	fmt.Printf("Packed %d entries from %s into %s\n", len(pkg.Entries), dir, odtPath)
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// CleanupOptions controls the gc-styles command
+type CleanupOptions struct {
+	Common   bool   // also remove unused common styles
+	KeepFile string // common styles to keep even when unused, one per line
+}
+
+// loadKeepList reads style names, internal or display, one per line.
+// Lines starting with # are comments.
+func loadKeepList(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+	return names, nil
+}
+
+// removeUnusedStyles deletes the styles nothing in the document uses, in
+// the main document and each embedded object, and reports them
+func removeUnusedStyles(odtPath, outputPath string, options CleanupOptions) error {
+	var keep []string
+	if options.KeepFile != "" {
+		var err error
+		if keep, err = loadKeepList(options.KeepFile); err != nil {
+			return err
+		}
+	}
+
+	doc, err := odf.OpenDocument(odtPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", odtPath, err)
+	}
+	defer doc.Close()
+
+	fmt.Printf("\n=== UNUSED STYLES ===\n")
+	total := 0
+	for _, prefix := range doc.SubDocuments() {
+		removed, err := doc.RemoveUnusedStyles(prefix, options.Common, keep)
+		if err != nil {
+			return fmt.Errorf("failed to remove unused styles: %w", err)
+		}
+		part, family := "", ""
+		for _, node := range removed {
+			if node.Part != part || node.Key.Family != family {
+				part, family = node.Part, node.Key.Family
+				fmt.Printf("\n%s, %s styles:\n", part, family)
+			}
+			fmt.Printf("  %s\n", node.Key.Name)
+		}
+		total += len(removed)
+	}
+	fmt.Printf("\nRemoved %d unused styles\n", total)
+
+	if err := doc.Save(outputPath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	fmt.Printf("Saved %s\n", outputPath)
+	return nil
+}
+
+// stripRsids removes LibreOffice's editing session ids from a document and
+// the automatic styles and spans that only existed to carry them
+func stripRsids(doc *odf.Document) error {
+	properties, styles, spans := 0, 0, 0
+	for _, prefix := range doc.SubDocuments() {
+		result, err := doc.StripRsids(prefix)
+		if err != nil {
+			return fmt.Errorf("failed to strip rsids: %w", err)
+		}
+		properties += result.Properties
+		styles += len(result.Styles)
+		spans += result.Spans
+	}
+	fmt.Printf("Stripped %d rsids, removing %d emptied automatic styles and unwrapping %d spans\n", properties, styles, spans)
+	return nil
+}
+
+// stripRsidsInODT runs stripRsids on its own
+func stripRsidsInODT(odtPath, outputPath string) error {
+	doc, err := odf.OpenDocument(odtPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", odtPath, err)
+	}
+	defer doc.Close()
+
+	if err := stripRsids(doc); err != nil {
+		return err
+	}
+	if err := doc.Save(outputPath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	fmt.Printf("Saved %s\n", outputPath)
+	return nil
+}
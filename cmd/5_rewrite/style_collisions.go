@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// CollisionPolicy says what to do when a style is renamed to a name that
+// another style of the same family already has
+type CollisionPolicy string
+
+const (
+	CollisionFail   CollisionPolicy = "fail"   // stop without changing anything
+	CollisionMerge  CollisionPolicy = "merge"  // use the existing style and drop the renamed one
+	CollisionSuffix CollisionPolicy = "suffix" // use the first free name of "Name 2", "Name 3"...
+)
+
+// Collision records a rename whose new name was already taken
+type Collision struct {
+	Mapping StyleMapping    // the rename actually applied
+	Taken   string          // the name that was already in use
+	Policy  CollisionPolicy // what was done about it
+	Differs bool            // the two definitions had different properties
+}
+
+// parseCollisionPolicy checks a policy given on the command line
+func parseCollisionPolicy(value string) (CollisionPolicy, error) {
+	switch policy := CollisionPolicy(value); policy {
+	case CollisionFail, CollisionMerge, CollisionSuffix:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown collision policy '%s', expected fail, merge or suffix", value)
+}
+
+// avoidCollisions checks each mapping against the styles that will exist
+// once the renames before it are done, and applies the collision policy to
+// any whose new name is taken. A mapping for any family that collides in
+// some family gets a mapping of its own for that family.
+func (sr *StyleRenamer) avoidCollisions(defs map[odf.StyleKey]*xmltree.Node, mappings []StyleMapping) ([]StyleMapping, error) {
+	current := make(map[odf.StyleKey]*xmltree.Node)
+	for key, definition := range defs {
+		current[key] = definition
+	}
+	sr.Collisions = nil
+	sr.dropped = make(map[odf.StyleKey]bool)
+
+	var failed []string
+	var result []StyleMapping
+	for _, mapping := range mappings {
+		families := sourceFamilies(defs, mapping)
+		var specific []StyleMapping
+		for _, family := range families {
+			source := odf.StyleKey{Family: family, Name: mapping.OldStyleName}
+			target := odf.StyleKey{Family: family, Name: mapping.NewStyleName}
+			definition := current[source]
+			delete(current, source)
+			existing := current[target]
+			if existing == nil || source == target {
+				current[target] = definition
+				continue
+			}
+
+			collision := Collision{Taken: mapping.NewStyleName, Policy: CollisionFail, Differs: !odf.SameDefinition(definition, existing)}
+			applied := mapping
+			applied.Family = family
+			switch sr.OnCollision {
+			case CollisionMerge:
+				applied.NewDisplayName = ""
+				collision.Policy = CollisionMerge
+				sr.dropped[source] = true
+			case CollisionSuffix:
+				collision.Policy = CollisionSuffix
+				applied.NewStyleName, applied.NewDisplayName = freeStyleName(current, mapping, family)
+				current[odf.StyleKey{Family: family, Name: applied.NewStyleName}] = definition
+			default:
+				failed = append(failed, fmt.Sprintf("%s %s -> %s", family, mapping.OldStyleName, mapping.NewStyleName))
+			}
+			collision.Mapping = applied
+			sr.Collisions = append(sr.Collisions, collision)
+			specific = append(specific, applied)
+		}
+
+		// Family-specific mappings go first, since the first match wins
+		result = append(result, specific...)
+		if len(specific) == 0 || len(specific) < len(families) {
+			result = append(result, mapping)
+		}
+	}
+
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("new style names already in use: %s (use --collisions=merge or --collisions=suffix)", strings.Join(failed, ", "))
+	}
+	return result, nil
+}
+
+// sourceFamilies lists the families in which a mapping renames a defined style
+func sourceFamilies(defs map[odf.StyleKey]*xmltree.Node, mapping StyleMapping) []string {
+	var families []string
+	for key := range defs {
+		if key.Name == mapping.OldStyleName && (mapping.Family == "" || key.Family == mapping.Family) {
+			families = append(families, key.Family)
+		}
+	}
+	sort.Strings(families)
+	return families
+}
+
+// freeStyleName finds the first "New Name 2", "New Name 3"... not yet
+// used in the family, returning its internal and display names
+func freeStyleName(current map[odf.StyleKey]*xmltree.Node, mapping StyleMapping, family string) (string, string) {
+	base := mapping.NewDisplayName
+	if base == "" {
+		base = mapping.NewStyleName
+	}
+	for n := 2; ; n++ {
+		display := fmt.Sprintf("%s %d", base, n)
+		name := odf.EncodeStyleName(display)
+		if current[odf.StyleKey{Family: family, Name: name}] == nil {
+			return name, display
+		}
+	}
+}
+
+// printCollisions lists the renames whose new name was already taken
+func (sr *StyleRenamer) printCollisions() {
+	if len(sr.Collisions) == 0 {
+		return
+	}
+	fmt.Println("\n=== Collisions ===")
+	for _, collision := range sr.Collisions {
+		mapping := collision.Mapping
+		properties := "same properties"
+		if collision.Differs {
+			properties = "properties differ"
+		}
+		switch collision.Policy {
+		case CollisionMerge:
+			fmt.Printf("  %s %s: merged into existing %s, definition dropped (%s)\n",
+				mapping.Family, mapping.OldStyleName, collision.Taken, properties)
+		case CollisionSuffix:
+			fmt.Printf("  %s %s: %s already exists, renamed to %s instead\n",
+				mapping.Family, mapping.OldStyleName, collision.Taken, mapping.NewStyleName)
+		default:
+			fmt.Printf("  %s %s: %s already exists (%s)\n",
+				mapping.Family, mapping.OldStyleName, collision.Taken, properties)
+		}
+	}
+}
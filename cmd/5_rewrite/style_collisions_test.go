@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// testPart wraps office:styles definitions and office:text content in a
+// document-content element
+func testPart(styles, text string) string {
+	return `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+		` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0">` +
+		`<office:styles>` + styles + `</office:styles>` +
+		`<office:body><office:text>` + text + `</office:text></office:body></office:document-content>`
+}
+
+// renameTestPart runs the renamer over a part, returning what it wrote
+func renameTestPart(t *testing.T, sr *StyleRenamer, part string) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "in.xml"), filepath.Join(dir, "out.xml")
+	if err := os.WriteFile(input, []byte(part), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.RenameStyleInFile(input, output); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), nil
+}
+
+func TestRenameCollisions(t *testing.T) {
+	const (
+		quotations = `<style:style style:name="Quotations" style:family="paragraph"><style:paragraph-properties fo:margin-left="1in"/></style:style>`
+		quote      = `<style:style style:name="Quote" style:family="paragraph"><style:paragraph-properties fo:margin-left="1in"/></style:style>`
+		wideQuote  = `<style:style style:name="Quote" style:family="paragraph"><style:paragraph-properties fo:margin-left="2in"/></style:style>`
+		quote2     = `<style:style style:name="Quote_20_2" style:display-name="Quote 2" style:family="paragraph"/>`
+		textQuote  = `<style:style style:name="Quote" style:family="text"/>`
+		text       = `<text:p text:style-name="Quotations">a</text:p><text:p text:style-name="Quote">b</text:p>`
+	)
+
+	tests := []struct {
+		name       string
+		styles     string
+		policy     CollisionPolicy
+		wantErr    string
+		want       []string // in the output
+		unwanted   []string
+		collisions []Collision
+	}{
+		{
+			name:    "fail",
+			styles:  quotations + quote,
+			policy:  CollisionFail,
+			wantErr: "new style names already in use: paragraph Quotations -> Quote (use --collisions=merge or --collisions=suffix)",
+		},
+		{
+			name:     "merge into the same definition",
+			styles:   quotations + quote,
+			policy:   CollisionMerge,
+			want:     []string{`<text:p text:style-name="Quote">a</text:p>`, quote},
+			unwanted: []string{`style:name="Quotations"`},
+			collisions: []Collision{{
+				Mapping: StyleMapping{Family: "paragraph", OldStyleName: "Quotations", NewStyleName: "Quote"},
+				Taken:   "Quote", Policy: CollisionMerge,
+			}},
+		},
+		{
+			name:     "merge into a different definition",
+			styles:   quotations + wideQuote,
+			policy:   CollisionMerge,
+			want:     []string{`<text:p text:style-name="Quote">a</text:p>`, wideQuote},
+			unwanted: []string{`fo:margin-left="1in"`},
+			collisions: []Collision{{
+				Mapping: StyleMapping{Family: "paragraph", OldStyleName: "Quotations", NewStyleName: "Quote"},
+				Taken:   "Quote", Policy: CollisionMerge, Differs: true,
+			}},
+		},
+		{
+			name:   "suffix",
+			styles: quotations + quote,
+			policy: CollisionSuffix,
+			want: []string{
+				`<style:style style:name="Quote_20_2" style:family="paragraph" style:display-name="Quote 2">`,
+				`<text:p text:style-name="Quote_20_2">a</text:p><text:p text:style-name="Quote">b</text:p>`,
+			},
+			collisions: []Collision{{
+				Mapping: StyleMapping{Family: "paragraph", OldStyleName: "Quotations", NewStyleName: "Quote_20_2", NewDisplayName: "Quote 2"},
+				Taken:   "Quote", Policy: CollisionSuffix,
+			}},
+		},
+		{
+			name:   "suffix skips names in use",
+			styles: quotations + quote + quote2,
+			policy: CollisionSuffix,
+			want:   []string{`style:name="Quote_20_3"`, `<text:p text:style-name="Quote_20_3">a</text:p>`},
+			collisions: []Collision{{
+				Mapping: StyleMapping{Family: "paragraph", OldStyleName: "Quotations", NewStyleName: "Quote_20_3", NewDisplayName: "Quote 3"},
+				Taken:   "Quote", Policy: CollisionSuffix,
+			}},
+		},
+		{
+			name:   "a name taken in another family doesn't collide",
+			styles: quotations + textQuote,
+			policy: CollisionFail,
+			want:   []string{`<style:style style:name="Quote" style:family="paragraph">`, textQuote},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr := &StyleRenamer{
+				Mappings:    []StyleMapping{{Family: "paragraph", OldStyleName: "Quotations", NewStyleName: "Quote"}},
+				OnCollision: tt.policy,
+			}
+			got, err := renameTestPart(t, sr, testPart(tt.styles, text))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenameStyleInFile: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output lacks %s:\n%s", want, got)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("output has %s:\n%s", unwanted, got)
+				}
+			}
+			if len(sr.Collisions) != len(tt.collisions) {
+				t.Fatalf("collisions = %+v, want %+v", sr.Collisions, tt.collisions)
+			}
+			for i := range tt.collisions {
+				if sr.Collisions[i] != tt.collisions[i] {
+					t.Errorf("collision = %+v, want %+v", sr.Collisions[i], tt.collisions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFreeStyleName(t *testing.T) {
+	taken := func(names ...string) map[odf.StyleKey]*xmltree.Node {
+		current := make(map[odf.StyleKey]*xmltree.Node)
+		for _, name := range names {
+			current[odf.StyleKey{Family: "paragraph", Name: name}] = &xmltree.Node{}
+		}
+		return current
+	}
+	tests := []struct {
+		name        string
+		current     map[odf.StyleKey]*xmltree.Node
+		mapping     StyleMapping
+		family      string
+		wantName    string
+		wantDisplay string
+	}{
+		{"display name", taken("Text_20_body"), StyleMapping{NewStyleName: "Text_20_body", NewDisplayName: "Text body"}, "paragraph", "Text_20_body_20_2", "Text body 2"},
+		{"internal name", taken("Body"), StyleMapping{NewStyleName: "Body"}, "paragraph", "Body_20_2", "Body 2"},
+		{"next free", taken("Body", "Body_20_2", "Body_20_3"), StyleMapping{NewStyleName: "Body"}, "paragraph", "Body_20_4", "Body 4"},
+		{"free in the family", taken("Body", "Body_20_2"), StyleMapping{NewStyleName: "Body"}, "text", "Body_20_2", "Body 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, display := freeStyleName(tt.current, tt.mapping, tt.family)
+			if name != tt.wantName || display != tt.wantDisplay {
+				t.Errorf("freeStyleName = %q, %q, want %q, %q", name, display, tt.wantName, tt.wantDisplay)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// ImportOptions controls the import-styles command
+type ImportOptions struct {
+	Existing odf.ImportPolicy // what to do with styles the document defines differently
+	All      bool             // import every style of the template, not just the missing ones
+	DryRun   bool             // only report what would be imported
+}
+
+// parseImportPolicy reads the value of --existing
+func parseImportPolicy(value string) (odf.ImportPolicy, error) {
+	switch policy := odf.ImportPolicy(value); policy {
+	case odf.ImportKeep, odf.ImportOverwrite, odf.ImportReport:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown --existing policy '%s', expected keep, overwrite or report", value)
+}
+
+// importStyles copies style definitions from a house template into a
+// document: by default the styles the document refers to but doesn't
+// define, such as the targets of a rename-map, or with All every common
+// style and master page of the template
+func importStyles(odtPath, templatePath, outputPath string, options ImportOptions) error {
+	doc, err := odf.OpenDocument(odtPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", odtPath, err)
+	}
+	defer doc.Close()
+
+	template, err := odf.OpenDocument(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to open template %s: %w", templatePath, err)
+	}
+	defer template.Close()
+
+	var keys []odf.StyleKey
+	if options.All {
+		keys, err = template.TemplateStyles()
+	} else {
+		var index *odf.StyleIndex
+		if index, err = doc.IndexStyles(""); err == nil {
+			keys = index.Dangling()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := doc.ImportStyles(template, keys, options.Existing)
+	if err != nil {
+		return fmt.Errorf("failed to import styles from %s: %w", templatePath, err)
+	}
+	printImport(result, options.DryRun)
+	if options.Existing == odf.ImportReport {
+		if err := printDifferences(doc, template, result.Differing); err != nil {
+			return err
+		}
+	}
+
+	if options.DryRun {
+		return nil
+	}
+	if err := doc.Save(outputPath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	fmt.Printf("Saved %s\n", outputPath)
+	return nil
+}
+
+// printImport reports what was imported, or what would be on a dry run
+func printImport(result *odf.ImportResult, dryRun bool) {
+	verb, overwrote := "Imported", "Overwrote"
+	if dryRun {
+		verb, overwrote = "Would import", "Would overwrite"
+	}
+	fmt.Printf("\n=== STYLE IMPORT ===\n")
+	for _, key := range result.Imported {
+		if renamed, ok := result.Renamed[key]; ok {
+			fmt.Printf("%s %s style '%s' as '%s'\n", verb, key.Family, key.Name, renamed)
+		} else {
+			fmt.Printf("%s %s style '%s'\n", verb, key.Family, key.Name)
+		}
+	}
+	for _, key := range result.Overwritten {
+		fmt.Printf("%s %s style '%s'\n", overwrote, key.Family, key.Name)
+	}
+	for _, key := range result.Differing {
+		fmt.Printf("Kept the document's own %s style '%s', which differs from the template's\n", key.Family, key.Name)
+	}
+	for _, key := range result.Missing {
+		fmt.Printf("The template doesn't define %s style '%s' either\n", key.Family, key.Name)
+	}
+	fmt.Printf("%d imported, %d overwritten, %d differing, %d missing\n",
+		len(result.Imported), len(result.Overwritten), len(result.Differing), len(result.Missing))
+}
+
+// printDifferences shows the document's and the template's definitions of
+// each style they define differently, for the report policy
+func printDifferences(doc, template *odf.Document, keys []odf.StyleKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ours, err := doc.Part("styles.xml")
+	if err != nil {
+		return fmt.Errorf("failed to read styles.xml: %w", err)
+	}
+	theirs, err := template.Part("styles.xml")
+	if err != nil {
+		return fmt.Errorf("failed to read styles.xml of the template: %w", err)
+	}
+	documentDefs, templateDefs := odf.Definitions(ours.Root), odf.Definitions(theirs.Root)
+
+	fmt.Printf("\n=== DIFFERING STYLES ===\n")
+	for _, key := range keys {
+		fmt.Printf("%s style '%s'\n", key.Family, key.Name)
+		fmt.Printf("  document: %s\n", documentDefs[key])
+		fmt.Printf("  template: %s\n", templateDefs[key])
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// writeTestODT writes a document whose styles.xml holds the given
+// office:styles content, and whose text uses the given paragraph style
+func writeTestODT(t *testing.T, name, styles, paragraphStyle string) string {
+	t.Helper()
+	const namespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+		` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"`
+	pkg := odf.New()
+	pkg.Add(odf.MimetypePath, zip.Store, []byte("application/vnd.oasis.opendocument.text"))
+	pkg.Add(odf.ManifestPath, zip.Deflate, []byte(`<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0">`+
+		`<manifest:file-entry manifest:full-path="/" manifest:media-type="application/vnd.oasis.opendocument.text"/>`+
+		`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>`+
+		`<manifest:file-entry manifest:full-path="styles.xml" manifest:media-type="text/xml"/>`+
+		`</manifest:manifest>`))
+	pkg.Add("styles.xml", zip.Deflate, []byte(`<office:document-styles `+namespaces+`><office:styles>`+styles+`</office:styles></office:document-styles>`))
+	pkg.Add("content.xml", zip.Deflate, []byte(`<office:document-content `+namespaces+`><office:body><office:text>`+
+		`<text:p text:style-name="`+paragraphStyle+`">x</text:p></office:text></office:body></office:document-content>`))
+	filename := filepath.Join(t.TempDir(), name)
+	if err := pkg.WriteFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestImportStylesCommand(t *testing.T) {
+	const (
+		body    = `<style:style style:name="Body" style:family="paragraph"><style:paragraph-properties fo:margin-top="0.1in"/></style:style>`
+		ownBody = `<style:style style:name="Body" style:family="paragraph"><style:paragraph-properties fo:margin-top="1in"/></style:style>`
+		headA   = `<style:style style:name="HeadA" style:family="paragraph"><style:text-properties fo:font-weight="bold"/></style:style>`
+	)
+	template := writeTestODT(t, "template.ott", body+headA, "Body")
+
+	tests := []struct {
+		name    string
+		options ImportOptions
+		written bool
+		want    []string // in the output's styles.xml
+		unwant  []string
+	}{
+		{"keep", ImportOptions{Existing: odf.ImportKeep, All: true}, true, []string{`style:name="HeadA"`, `fo:margin-top="1in"`}, []string{`fo:margin-top="0.1in"`}},
+		{"overwrite", ImportOptions{Existing: odf.ImportOverwrite, All: true}, true, []string{`style:name="HeadA"`, `fo:margin-top="0.1in"`}, []string{`fo:margin-top="1in"`}},
+		{"report still imports", ImportOptions{Existing: odf.ImportReport, All: true}, true, []string{`style:name="HeadA"`, `fo:margin-top="1in"`}, []string{`fo:margin-top="0.1in"`}},
+		{"dangling references only", ImportOptions{Existing: odf.ImportOverwrite}, true, []string{`style:name="HeadA"`, `fo:margin-top="1in"`}, nil},
+		{"dry run writes nothing", ImportOptions{Existing: odf.ImportOverwrite, All: true, DryRun: true}, false, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := writeTestODT(t, "doc.odt", ownBody, "HeadA")
+			output := filepath.Join(t.TempDir(), "out.odt")
+			if err := importStyles(input, template, output, tt.options); err != nil {
+				t.Fatalf("importStyles: %v", err)
+			}
+			if _, err := os.Stat(output); !tt.written {
+				if !os.IsNotExist(err) {
+					t.Errorf("dry run wrote %s", output)
+				}
+				return
+			}
+
+			doc, err := odf.OpenDocument(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doc.Close()
+			part, err := doc.Part("styles.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := part.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("styles.xml lacks %s", want)
+				}
+			}
+			for _, unwanted := range tt.unwant {
+				if strings.Contains(string(data), unwanted) {
+					t.Errorf("styles.xml has %s", unwanted)
+				}
+			}
+		})
+	}
+}
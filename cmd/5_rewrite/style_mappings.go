@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// StyleMapping renames one style. An empty Family matches a style of any
+// family. NewDisplayName is worked out from NewStyleName when the renamer
+// resolves the mapping against a document.
+type StyleMapping struct {
+	Family         string
+	OldStyleName   string
+	NewStyleName   string
+	NewDisplayName string
+}
+
+// String describes the mapping for reports
+func (m StyleMapping) String() string {
+	family := m.Family
+	if family == "" {
+		family = "any"
+	}
+	return fmt.Sprintf("%s %s -> %s", family, m.OldStyleName, m.NewStyleName)
+}
+
+// LoadStyleMappings reads a CSV mapping file with one rename per row:
+// family, old style name, new style name. Lines starting with # are comments.
+func (sr *StyleRenamer) LoadStyleMappings(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	lineCount := 0
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading CSV line %d: %w", lineCount+1, err)
+		}
+
+		lineCount++
+
+		// Skip comment lines
+		if len(record) > 0 && strings.HasPrefix(record[0], "#") {
+			continue
+		}
+
+		// Ensure we have all three columns
+		if len(record) < 3 {
+			log.Printf("Warning: skipping line %d - insufficient columns", lineCount)
+			continue
+		}
+
+		mapping := StyleMapping{
+			Family:       strings.TrimSpace(record[0]),
+			OldStyleName: strings.TrimSpace(record[1]),
+			NewStyleName: strings.TrimSpace(record[2]),
+		}
+
+		if mapping.Family != "" && !odf.KnownFamily(mapping.Family) {
+			log.Printf("Warning: unknown style family '%s' on line %d", mapping.Family, lineCount)
+			continue
+		}
+		if mapping.OldStyleName == "" || mapping.NewStyleName == "" {
+			log.Printf("Warning: skipping line %d - empty style name", lineCount)
+			continue
+		}
+
+		sr.Mappings = append(sr.Mappings, mapping)
+	}
+
+	fmt.Printf("Loaded %d style mappings from %s\n", len(sr.Mappings), filename)
+	return nil
+}
+
+// PrintReport displays how many references each mapping changed
+func (sr *StyleRenamer) PrintReport() {
+	fmt.Println("\n=== Rename Report ===")
+
+	total := 0
+	for _, mapping := range sr.mappings() {
+		fmt.Printf("  %s: %d references", mapping, sr.Counts[mapping])
+		if sr.Indirect[mapping] > 0 {
+			fmt.Printf(", %d more through automatic styles", sr.Indirect[mapping])
+		}
+		fmt.Println()
+		total += sr.Counts[mapping]
+	}
+	fmt.Printf("Total references changed: %d\n", total)
+
+	if len(sr.Folded) > 0 {
+		fmt.Printf("Folded %d automatic styles into their renamed parents:\n", len(sr.Folded))
+		for _, name := range sr.Folded {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+
+	sr.printCollisions()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// checkODT reports every style reference that has no definition of the
+// right family, in the document and in each embedded object
+func checkODT(odtPath string) error {
+	doc, err := odf.OpenDocument(odtPath)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+
+	problems := 0
+	for _, prefix := range doc.SubDocuments() {
+		index, err := doc.IndexStyles(prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range index.Dangling() {
+			sites := index.References[key]
+			fmt.Printf("%s: missing %s style '%s' is referenced %d times, first by <%s>\n",
+				sites[0].Part, key.Family, key.Name, len(sites), sites[0].Element.QName())
+			problems += len(sites)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d dangling style references in %s", problems, odtPath)
+	}
+	fmt.Printf("All style references in %s resolve\n", odtPath)
+	return nil
+}
+
+// listStyles prints every style defined in the document by family, with
+// the number of places each one is referenced
+func listStyles(odtPath string) error {
+	doc, err := odf.OpenDocument(odtPath)
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+
+	index, err := doc.IndexStyles("")
+	if err != nil {
+		return err
+	}
+
+	family := ""
+	for _, key := range index.Keys() {
+		if key.Family != family {
+			family = key.Family
+			fmt.Printf("\n%s STYLES:\n", strings.ToUpper(family))
+			fmt.Println(strings.Repeat("-", 30))
+		}
+		fmt.Printf("  %-30s %d references\n", key.Name, len(index.References[key]))
+	}
+	fmt.Printf("\nTotal styles found: %d\n", len(index.Definitions))
+	return nil
+}
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
 	"encoding/csv"
 	"encoding/xml"
 	"fmt"
@@ -11,23 +9,18 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-)
-
-// FormattingType represents the type of direct formatting found
-type FormattingType string
 
-const (
-	Bold        FormattingType = "Bold"
-	Italic      FormattingType = "Italic"
-	BoldItalic  FormattingType = "Bold Italic"
-	Superscript FormattingType = "Superscript"
-	Subscript   FormattingType = "Subscript"
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
 )
 
 // ChangeTracker tracks the changes made during conversion
 type ChangeTracker struct {
 	TotalChanges int
 	StyleCounts  map[string]int
+	Ambiguous    []string       // spans left alone because rules tied
+	Unmatched    int            // spans with direct formatting no rule matched
+	Dropped      map[string]int // spans restyled by a rule that lost formatting it doesn't cover, by property
 }
 
 // NewChangeTracker creates a new change tracker
@@ -35,6 +28,7 @@ func NewChangeTracker() *ChangeTracker {
 	return &ChangeTracker{
 		TotalChanges: 0,
 		StyleCounts:  make(map[string]int),
+		Dropped:      make(map[string]int),
 	}
 }
 
@@ -44,21 +38,38 @@ func (ct *ChangeTracker) AddChange(styleName string) {
 	ct.StyleCounts[styleName]++
 }
 
+// AddAmbiguous records a span that more than one rule matched equally well
+func (ct *ChangeTracker) AddAmbiguous(text string, rules []*Rule) {
+	var styles []string
+	for _, rule := range rules {
+		styles = append(styles, rule.String())
+	}
+	ct.Ambiguous = append(ct.Ambiguous, fmt.Sprintf("%q matches %s", text, strings.Join(styles, ", ")))
+}
+
 // LibreOfficeConverter handles the conversion process
 type LibreOfficeConverter struct {
-	formattingMap map[FormattingType]string
-	changeTracker *ChangeTracker
+	rules          []*Rule
+	literalFonts   []string
+	template       *odf.Document // template to copy missing styles from, if any
+	templateStyles *xmltree.Node // office:styles of the template
+	doc            *odf.Document // the document being converted
+	commonStyles   *xmltree.Node // office:styles of the document being converted
+	changeTracker  *ChangeTracker
 }
 
 // NewLibreOfficeConverter creates a new converter instance
 func NewLibreOfficeConverter() *LibreOfficeConverter {
 	return &LibreOfficeConverter{
-		formattingMap: make(map[FormattingType]string),
 		changeTracker: NewChangeTracker(),
 	}
 }
 
-// LoadStyleMappings reads the CSV file and creates the formatting map
+// LoadStyleMappings reads the CSV file of rules. Each line holds the
+// formatting predicates a span must satisfy, separated by spaces, and the
+// character style to give it, as in "weight=bold style=italic,BoldItalic".
+// Lines of the form "monospace-font,Source Code Pro" list the font families
+// the monospace predicate treats as literal, in place of the defaults.
 func (loc *LibreOfficeConverter) LoadStyleMappings(filename string) error {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -67,6 +78,7 @@ func (loc *LibreOfficeConverter) LoadStyleMappings(filename string) error {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 	lineCount := 0
 
 	for {
@@ -91,70 +103,63 @@ func (loc *LibreOfficeConverter) LoadStyleMappings(filename string) error {
 			continue
 		}
 
-		formattingType := FormattingType(strings.TrimSpace(record[0]))
-		characterStyle := strings.TrimSpace(record[1])
+		if strings.TrimSpace(record[0]) == "monospace-font" {
+			loc.literalFonts = append(loc.literalFonts, strings.TrimSpace(record[1]))
+			continue
+		}
 
-		// Validate formatting type
-		if !isValidFormattingType(formattingType) {
-			log.Printf("Warning: unknown formatting type '%s' on line %d", formattingType, lineCount)
+		predicates, err := parsePredicates(strings.TrimSpace(record[0]))
+		if err != nil {
+			log.Printf("Warning: skipping line %d - %v", lineCount, err)
+			continue
+		}
+		characterStyle := strings.TrimSpace(record[1])
+		if characterStyle == "" {
+			log.Printf("Warning: skipping line %d - empty character style", lineCount)
 			continue
 		}
 
-		loc.formattingMap[formattingType] = characterStyle
+		loc.rules = append(loc.rules, &Rule{Predicates: predicates, Style: characterStyle, Line: lineCount})
 	}
 
-	fmt.Printf("Loaded %d style mappings from %s\n", len(loc.formattingMap), filename)
-	return nil
-}
-
-// isValidFormattingType checks if the formatting type is supported
-func isValidFormattingType(ft FormattingType) bool {
-	switch ft {
-	case Bold, Italic, BoldItalic, Superscript, Subscript:
-		return true
-	default:
-		return false
+	if loc.literalFonts == nil {
+		loc.literalFonts = odf.DefaultLiteralFonts
 	}
-}
-
-// ODT XML structures for parsing content.xml
-type ODTDocument struct {
-	XMLName xml.Name           `xml:"document-content"`
-	Body    ODTBody            `xml:"body"`
-	Styles  ODTAutomaticStyles `xml:"automatic-styles"`
-}
-
-type ODTBody struct {
-	Text ODTText `xml:"text"`
-}
 
-type ODTText struct {
-	Paragraphs []ODTParagraph `xml:"p"`
+	fmt.Printf("Loaded %d style mappings from %s\n", len(loc.rules), filename)
+	return nil
 }
 
-type ODTParagraph struct {
-	XMLName   xml.Name `xml:"p"`
-	StyleName string   `xml:"style-name,attr,omitempty"`
-	Content   []byte   `xml:",innerxml"`
-}
+// LoadTemplate opens a template (.ott or .odt) whose character styles are
+// copied into documents that don't have them rather than created from the
+// rules. The template stays open until Close.
+func (loc *LibreOfficeConverter) LoadTemplate(templatePath string) error {
+	template, err := odf.OpenDocument(templatePath)
+	if err != nil {
+		return err
+	}
 
-type ODTAutomaticStyles struct {
-	XMLName xml.Name   `xml:"automatic-styles"`
-	Styles  []ODTStyle `xml:"style"`
-}
+	styles, err := template.Part("styles.xml")
+	if err != nil {
+		template.Close()
+		return fmt.Errorf("failed to parse styles.xml of %s: %w", templatePath, err)
+	}
+	if styles == nil || styles.Root.Child(odf.NSOffice, "styles") == nil {
+		template.Close()
+		return fmt.Errorf("template %s has no styles", templatePath)
+	}
+	loc.template = template
+	loc.templateStyles = styles.Root.Child(odf.NSOffice, "styles")
 
-type ODTStyle struct {
-	XMLName   xml.Name           `xml:"style"`
-	Name      string             `xml:"name,attr"`
-	Family    string             `xml:"family,attr"`
-	TextProps *ODTTextProperties `xml:"text-properties,omitempty"`
+	fmt.Printf("Loaded template styles from %s\n", templatePath)
+	return nil
 }
 
-type ODTTextProperties struct {
-	XMLName      xml.Name `xml:"text-properties"`
-	FontWeight   string   `xml:"font-weight,attr,omitempty"`
-	FontStyle    string   `xml:"font-style,attr,omitempty"`
-	TextPosition string   `xml:"text-position,attr,omitempty"`
+// Close closes the template, if one was loaded
+func (loc *LibreOfficeConverter) Close() {
+	if loc.template != nil {
+		loc.template.Close()
+	}
 }
 
 // ProcessODTFile reads an ODT file, processes it, and saves the result
@@ -176,22 +181,19 @@ func (loc *LibreOfficeConverter) ProcessODTFile(inputPath string) error {
 	}
 
 	// Read ODT file (it's a ZIP archive)
-	odtContent, err := loc.readODTFile(inputPath)
+	doc, err := loc.readODTFile(inputPath)
 	if err != nil {
 		return fmt.Errorf("error reading ODT file: %w", err)
 	}
+	defer doc.Close()
 
-	// Process the content.xml
-	modified, err := loc.processContentXML(odtContent["content.xml"])
-	if err != nil {
+	// Process content.xml, and the headers and footers in styles.xml
+	if err := loc.processDocument(doc); err != nil {
 		return fmt.Errorf("error processing content: %w", err)
 	}
 
-	// Update content in the ODT structure
-	odtContent["content.xml"] = modified
-
 	// Save the modified ODT file
-	err = loc.saveODTFile(odtContent, outputPath)
+	err = loc.saveODTFile(doc, outputPath)
 	if err != nil {
 		return fmt.Errorf("error saving ODT file: %w", err)
 	}
@@ -211,238 +213,209 @@ func generateOutputFilename(inputPath string) string {
 	return filepath.Join(dir, outputName)
 }
 
-// readODTFile reads an ODT file and returns its contents as a map
-func (loc *LibreOfficeConverter) readODTFile(filePath string) (map[string][]byte, error) {
+// readODTFile opens an ODT file, keeping its entries in archive order
+func (loc *LibreOfficeConverter) readODTFile(filePath string) (*odf.Document, error) {
 	fmt.Printf("Reading ODT file: %s\n", filePath)
 
-	reader, err := zip.OpenReader(filePath)
+	doc, err := odf.OpenDocument(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open ODT file as ZIP: %w", err)
+		return nil, err
 	}
-	defer reader.Close()
 
-	content := make(map[string][]byte)
-
-	for _, file := range reader.File {
-		rc, err := file.Open()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file %s from ODT: %w", file.Name, err)
-		}
-
-		data, err := io.ReadAll(rc)
-		rc.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read content of %s: %w", file.Name, err)
-		}
+	fmt.Printf("Successfully read ODT file with %d internal files\n", len(doc.Package.Entries))
+	return doc, nil
+}
 
-		content[file.Name] = data
+// processDocument converts direct formatting to character styles everywhere
+// text can appear: the body, with its lists, tables, frames, notes, sections
+// and indexes, and the headers and footers of the page styles
+func (loc *LibreOfficeConverter) processDocument(doc *odf.Document) error {
+	// Editing session ids split text into spans that look alike but have
+	// different automatic styles; they go before anything else
+	stripped, err := doc.StripRsids("")
+	if err != nil {
+		return fmt.Errorf("failed to strip rsids: %w", err)
 	}
+	fmt.Printf("Stripped %d rsids, removing %d emptied automatic styles and unwrapping %d spans\n",
+		stripped.Properties, len(stripped.Styles), stripped.Spans)
 
-	fmt.Printf("Successfully read ODT file with %d internal files\n", len(content))
-	return content, nil
-}
-
-// processContentXML processes the content.xml and converts direct formatting to character styles
-func (loc *LibreOfficeConverter) processContentXML(contentXML []byte) ([]byte, error) {
-	fmt.Println("Processing content.xml for direct formatting...")
+	styles, err := doc.Styles("")
+	if err != nil {
+		return err
+	}
 
-	// Parse the XML content
-	var doc ODTDocument
-	err := xml.Unmarshal(contentXML, &doc)
+	// Character styles are created as common styles, so that they show up
+	// in the Styles sidebar rather than as anonymous direct formatting
+	stylesPart, err := doc.Part("styles.xml")
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse content.xml: %w", err)
+		return fmt.Errorf("failed to parse styles.xml: %w", err)
 	}
+	if stylesPart == nil || stylesPart.Root.Child(odf.NSOffice, "styles") == nil {
+		return fmt.Errorf("styles.xml has no office:styles to add character styles to")
+	}
+	loc.commonStyles = stylesPart.Root.Child(odf.NSOffice, "styles")
+	loc.doc = doc
 
-	// Process each paragraph
-	for i := range doc.Body.Text.Paragraphs {
-		err := loc.processParagraph(&doc.Body.Text.Paragraphs[i], &doc.Styles)
+	for _, name := range []string{"content.xml", "styles.xml"} {
+		fmt.Printf("Processing %s for direct formatting...\n", name)
+		part, err := doc.Part(name)
 		if err != nil {
-			log.Printf("Warning: error processing paragraph %d: %v", i, err)
+			return fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if part == nil {
+			continue
 		}
-	}
 
-	// Marshal back to XML
-	modifiedXML, err := xml.MarshalIndent(&doc, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal modified XML: %w", err)
+		// Spans are classified by the formatting their styles give them
+		resolver := &formatResolver{styles: styles, part: name, literalFonts: loc.literalFonts}
+		for i, paragraph := range paragraphs(part.Root) {
+			if err := loc.processParagraph(paragraph, resolver); err != nil {
+				log.Printf("Warning: error processing paragraph %d of %s: %v", i, name, err)
+			}
+		}
 	}
-
-	// Add XML declaration
-	xmlHeader := []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
-	return append(xmlHeader, modifiedXML...), nil
+	return nil
 }
 
-// processParagraph processes a paragraph and its text spans for direct formatting
-func (loc *LibreOfficeConverter) processParagraph(paragraph *ODTParagraph, styles *ODTAutomaticStyles) error {
-	content := string(paragraph.Content)
-
-	// Look for text spans with direct formatting
-	// This is a simplified approach - real implementation would need proper XML parsing
-	if strings.Contains(content, `font-weight="bold"`) ||
-		strings.Contains(content, `font-style="italic"`) ||
-		strings.Contains(content, `text-position`) {
-
-		// Determine formatting type
-		var formattingType FormattingType
-		isBold := strings.Contains(content, `font-weight="bold"`)
-		isItalic := strings.Contains(content, `font-style="italic"`)
-		isSuper := strings.Contains(content, `text-position="super`)
-		isSub := strings.Contains(content, `text-position="sub`)
-
-		if isBold && isItalic {
-			formattingType = BoldItalic
-		} else if isBold {
-			formattingType = Bold
-		} else if isItalic {
-			formattingType = Italic
-		} else if isSuper {
-			formattingType = Superscript
-		} else if isSub {
-			formattingType = Subscript
-		} else {
-			return nil // No recognized formatting
+// paragraphs returns every text:p and text:h in a part, in document order,
+// including those nested in other paragraphs by notes and frames
+func paragraphs(root *xmltree.Node) []*xmltree.Node {
+	var found []*xmltree.Node
+	root.Walk(func(n *xmltree.Node) bool {
+		if isParagraph(n) {
+			found = append(found, n)
 		}
+		return n.Kind == xmltree.ElementNode
+	})
+	return found
+}
+
+// isParagraph reports whether n is a text:p or text:h
+func isParagraph(n *xmltree.Node) bool {
+	return n.Is(odf.NSText, "p") || n.Is(odf.NSText, "h")
+}
+
+// styleElement builds the style:style element for a new character style
+func styleElement(name string, textProperties []xmltree.Attr) *xmltree.Node {
+	element := xmltree.NewElement(xml.Name{Space: odf.NSStyle, Local: "style"})
+	element.SetAttr(odf.NSStyle, "name", name)
+	element.SetAttr(odf.NSStyle, "family", "text")
 
-		// Check if we have a mapping for this formatting type
-		characterStyle, exists := loc.formattingMap[formattingType]
-		if !exists {
-			return nil
+	props := xmltree.NewElement(xml.Name{Space: odf.NSStyle, Local: "text-properties"})
+	props.Attrs = textProperties
+	element.AppendChild(props)
+	return element
+}
+
+// processParagraph gives each span with direct formatting the character
+// style of the rule its own formatting matches, then tidies up the spans
+// left over
+func (loc *LibreOfficeConverter) processParagraph(paragraph *xmltree.Node, resolver *formatResolver) error {
+	// Classify every span before changing any, since a span's formatting
+	// depends on the spans around it
+	spans, formats := resolver.spanFormatting(paragraph)
+	plain := resolver.plainSpans(paragraph)
+
+	for i, span := range spans {
+		// Find the most specific rule for this span's formatting
+		rule, tied := matchRule(loc.rules, formats[i])
+		if tied != nil {
+			loc.changeTracker.AddAmbiguous(span.Text(), tied)
+			continue
+		}
+		if rule == nil {
+			loc.changeTracker.Unmatched++
+			continue
 		}
 
-		// Create or update character style in automatic styles
-		loc.ensureCharacterStyleExists(styles, characterStyle, formattingType)
+		// Create or copy the character style if the document lacks it
+		styleName, err := loc.ensureCharacterStyleExists(rule)
+		if err != nil {
+			return err
+		}
 
 		// Replace direct formatting with character style reference
-		modifiedContent := loc.replaceDirectFormattingWithStyle(content, characterStyle, formattingType)
-		paragraph.Content = []byte(modifiedContent)
+		span.SetAttr(odf.NSText, "style-name", styleName)
 
 		// Track the change
-		loc.changeTracker.AddChange(characterStyle)
+		loc.changeTracker.AddChange(rule.Style)
+
+		lost := rule.uncovered(formats[i])
+		for _, property := range lost {
+			loc.changeTracker.Dropped[property]++
+		}
+		if len(lost) > 0 {
+			fmt.Printf("Converted direct formatting to style '%s' by %s, dropping %s\n", rule.Style, rule, strings.Join(lost, ", "))
+		} else {
+			fmt.Printf("Converted direct formatting to style '%s' by %s\n", rule.Style, rule)
+		}
+	}
 
-		fmt.Printf("Converted direct %s formatting to style '%s'\n", formattingType, characterStyle)
+	// Spans that format nothing only get in the way of merging
+	for _, span := range plain {
+		span.Unwrap()
 	}
+	odf.MergeSpans(paragraph)
+	paragraph.Normalize()
 
 	return nil
 }
 
-// ensureCharacterStyleExists creates a character style if it doesn't exist
-func (loc *LibreOfficeConverter) ensureCharacterStyleExists(styles *ODTAutomaticStyles, styleName string, formattingType FormattingType) {
+// ensureCharacterStyleExists makes sure styles.xml has a common character
+// style for the rule, copying it from the template if it has one, with the
+// parent styles and font faces it depends on, and otherwise creating it
+// from the rule's predicates. It returns the style's internal name, which
+// differs from the rule's when that has spaces.
+func (loc *LibreOfficeConverter) ensureCharacterStyleExists(rule *Rule) (string, error) {
 	// Check if style already exists
-	for _, style := range styles.Styles {
-		if style.Name == styleName && style.Family == "text" {
-			return // Style already exists
-		}
+	if style := findCharacterStyle(loc.commonStyles, rule.Style); style != nil {
+		return style.AttrValue(odf.NSStyle, "name"), nil
 	}
 
-	// Create new character style
-	newStyle := ODTStyle{
-		Name:      styleName,
-		Family:    "text",
-		TextProps: &ODTTextProperties{},
+	if style := findCharacterStyle(loc.templateStyles, rule.Style); style != nil {
+		key := odf.StyleKey{Family: "text", Name: style.AttrValue(odf.NSStyle, "name")}
+		if _, err := loc.doc.ImportStyles(loc.template, []odf.StyleKey{key}, odf.ImportKeep); err != nil {
+			return "", fmt.Errorf("failed to copy %s from the template: %w", rule.Style, err)
+		}
+		fmt.Printf("Copied character style from template: %s\n", rule.Style)
+		return key.Name, nil
 	}
 
-	// Set properties based on formatting type
-	switch formattingType {
-	case Bold:
-		newStyle.TextProps.FontWeight = "bold"
-	case Italic:
-		newStyle.TextProps.FontStyle = "italic"
-	case BoldItalic:
-		newStyle.TextProps.FontWeight = "bold"
-		newStyle.TextProps.FontStyle = "italic"
-	case Superscript:
-		newStyle.TextProps.TextPosition = "super 58%"
-	case Subscript:
-		newStyle.TextProps.TextPosition = "sub 58%"
+	// Create new character style, with the properties the rule asks for
+	name := rule.Style
+	style := styleElement(odf.EncodeStyleName(name), rule.textProperties())
+	if odf.NeedsEncoding(name) {
+		style.SetAttr(odf.NSStyle, "display-name", name)
+		name = odf.EncodeStyleName(name)
 	}
-
-	// Add to automatic styles
-	styles.Styles = append(styles.Styles, newStyle)
-	fmt.Printf("Created character style: %s\n", styleName)
+	loc.commonStyles.AppendChild(style)
+	fmt.Printf("Created character style: %s\n", rule.Style)
+	return name, nil
 }
 
-// replaceDirectFormattingWithStyle replaces direct formatting with character style reference
-func (loc *LibreOfficeConverter) replaceDirectFormattingWithStyle(content, styleName string, formattingType FormattingType) string {
-	// This is a simplified implementation
-	// Real implementation would need proper XML manipulation
-
-	switch formattingType {
-	case Bold:
-		// Replace <text:span text:style-name="..." fo:font-weight="bold">
-		// with <text:span text:style-name="styleName">
-		content = strings.ReplaceAll(content, `fo:font-weight="bold"`, "")
-		content = strings.ReplaceAll(content, `font-weight="bold"`, "")
-	case Italic:
-		content = strings.ReplaceAll(content, `fo:font-style="italic"`, "")
-		content = strings.ReplaceAll(content, `font-style="italic"`, "")
-	case BoldItalic:
-		content = strings.ReplaceAll(content, `fo:font-weight="bold"`, "")
-		content = strings.ReplaceAll(content, `font-weight="bold"`, "")
-		content = strings.ReplaceAll(content, `fo:font-style="italic"`, "")
-		content = strings.ReplaceAll(content, `font-style="italic"`, "")
-	case Superscript, Subscript:
-		// Remove text-position attributes
-		content = strings.ReplaceAll(content, `text-position="super 58%"`, "")
-		content = strings.ReplaceAll(content, `text-position="sub 58%"`, "")
-	}
-
-	// Add style reference (simplified - real implementation needs proper XML handling)
-	if strings.Contains(content, `text:style-name="`) {
-		// Replace existing style name
-		content = replaceStyleName(content, styleName)
-	}
-
-	return content
-}
-
-// replaceStyleName replaces the style name in a text span (simplified implementation)
-func replaceStyleName(content, newStyleName string) string {
-	// This is a very basic replacement - real implementation would use proper XML parsing
-	start := strings.Index(content, `text:style-name="`)
-	if start == -1 {
-		return content
+// findCharacterStyle looks for a text style in an office:styles element by
+// internal or display name
+func findCharacterStyle(styles *xmltree.Node, name string) *xmltree.Node {
+	if styles == nil {
+		return nil
 	}
-
-	start += len(`text:style-name="`)
-	end := strings.Index(content[start:], `"`)
-	if end == -1 {
-		return content
+	for _, style := range styles.Elements() {
+		if !style.Is(odf.NSStyle, "style") || style.AttrValue(odf.NSStyle, "family") != "text" {
+			continue
+		}
+		if style.AttrValue(odf.NSStyle, "name") == name || style.AttrValue(odf.NSStyle, "display-name") == name {
+			return style
+		}
 	}
-
-	return content[:start] + newStyleName + content[start+end:]
+	return nil
 }
 
-// saveODTFile saves the modified ODT content to a new file
-func (loc *LibreOfficeConverter) saveODTFile(content map[string][]byte, outputPath string) error {
+// saveODTFile saves the modified ODT document to a new file
+func (loc *LibreOfficeConverter) saveODTFile(doc *odf.Document, outputPath string) error {
 	fmt.Printf("Saving converted ODT file to: %s\n", outputPath)
 
-	// Create output directory if it doesn't exist
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
-	}
-
-	// Create new ZIP file
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer file.Close()
-
-	zipWriter := zip.NewWriter(file)
-	defer zipWriter.Close()
-
-	// Write all files to the new ZIP
-	for filename, data := range content {
-		writer, err := zipWriter.Create(filename)
-		if err != nil {
-			return fmt.Errorf("failed to create file %s in ZIP: %w", filename, err)
-		}
-
-		_, err = io.Copy(writer, bytes.NewReader(data))
-		if err != nil {
-			return fmt.Errorf("failed to write file %s to ZIP: %w", filename, err)
-		}
+	if err := doc.Save(outputPath); err != nil {
+		return err
 	}
 
 	fmt.Printf("Successfully saved converted ODT file: %s\n", outputPath)
@@ -456,21 +429,37 @@ func (loc *LibreOfficeConverter) PrintReport() {
 
 	if loc.changeTracker.TotalChanges == 0 {
 		fmt.Println("No direct formatting found to convert.")
-		return
+	} else {
+		fmt.Println("Applied character styles:")
+		for styleName, count := range loc.changeTracker.StyleCounts {
+			fmt.Printf("  %s: %d changes\n", styleName, count)
+		}
 	}
 
-	fmt.Println("Applied character styles:")
-	for styleName, count := range loc.changeTracker.StyleCounts {
-		fmt.Printf("  %s: %d changes\n", styleName, count)
+	if loc.changeTracker.Unmatched > 0 {
+		fmt.Printf("Spans with direct formatting no rule matched: %d\n", loc.changeTracker.Unmatched)
+	}
+	if len(loc.changeTracker.Dropped) > 0 {
+		fmt.Println("Direct formatting dropped by restyled spans, as no rule predicate covers it:")
+		for property, count := range loc.changeTracker.Dropped {
+			fmt.Printf("  %s: %d spans\n", property, count)
+		}
+	}
+	if len(loc.changeTracker.Ambiguous) > 0 {
+		fmt.Printf("Ambiguous matches, left unchanged: %d\n", len(loc.changeTracker.Ambiguous))
+		for _, ambiguous := range loc.changeTracker.Ambiguous {
+			fmt.Printf("  %s\n", ambiguous)
+		}
 	}
 }
 
 func main() {
 	// Check command line arguments
 	if len(os.Args) < 2 {
-		fmt.Printf("Usage: %s <input-document.odt> [charstyles.txt]\n", os.Args[0])
+		fmt.Printf("Usage: %s <input-document.odt> [charstyles.txt] [template.ott]\n", os.Args[0])
 		fmt.Println("  input-document.odt: Path to the ODT document to process")
-		fmt.Println("  charstyles.txt: Optional path to character styles mapping file (default: charstyles.txt)")
+		fmt.Println("  charstyles.txt: Optional path to the rules mapping formatting to character styles (default: charstyles.txt)")
+		fmt.Println("  template.ott: Optional template to copy missing character styles from")
 		os.Exit(1)
 	}
 
@@ -494,6 +483,7 @@ func main() {
 
 	// Create converter instance
 	converter := NewLibreOfficeConverter()
+	defer converter.Close()
 
 	// Load style mappings from CSV file
 	err := converter.LoadStyleMappings(charStylesFile)
@@ -501,6 +491,13 @@ func main() {
 		log.Fatalf("Error loading style mappings: %v", err)
 	}
 
+	// Load the template character styles are copied from
+	if len(os.Args) >= 4 {
+		if err := converter.LoadTemplate(os.Args[3]); err != nil {
+			log.Fatalf("Error loading template: %v", err)
+		}
+	}
+
 	// Process the ODT file
 	err = converter.ProcessODTFile(inputFile)
 	if err != nil {
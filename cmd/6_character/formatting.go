@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/xml"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// Direct formatting rarely shows up in the paragraph itself: LibreOffice
+// gives each formatted span an automatic text style (T7) that carries
+// fo:font-weight and the like, so the formatting has to be worked out from
+// the styles of the span, of any spans around it and of the paragraph.
+
+// formatResolver works out the formatting of text in one part of a document
+type formatResolver struct {
+	styles       *odf.Styles
+	part         string
+	literalFonts []string // font families that count as monospace
+}
+
+// property returns the value of a text property in effect at n
+func (r *formatResolver) property(n *xmltree.Node, attr xml.Name) string {
+	return r.styles.TextProperty(r.part, n, attr)
+}
+
+// direct returns the formatting a span adds to the text around it
+func (r *formatResolver) direct(span *xmltree.Node) odf.TextFormat {
+	return r.styles.DirectFormat(r.part, span, r.literalFonts)
+}
+
+// spansOf calls fn for each span of a paragraph, leaving out the spans of
+// paragraphs nested in it by notes and frames, which are processed by
+// themselves
+func spansOf(paragraph *xmltree.Node, fn func(*xmltree.Node)) {
+	paragraph.Walk(func(n *xmltree.Node) bool {
+		if n != paragraph && isParagraph(n) {
+			return false
+		}
+		if n.Is(odf.NSText, "span") {
+			fn(n)
+		}
+		return n.Kind == xmltree.ElementNode
+	})
+}
+
+// spanFormatting returns the direct formatting of each span in a paragraph
+// that has any, in document order
+func (r *formatResolver) spanFormatting(paragraph *xmltree.Node) ([]*xmltree.Node, []odf.TextFormat) {
+	var spans []*xmltree.Node
+	var formats []odf.TextFormat
+	spansOf(paragraph, func(n *xmltree.Node) {
+		if format := r.direct(n); format != (odf.TextFormat{}) {
+			spans = append(spans, n)
+			formats = append(formats, format)
+		}
+	})
+	return spans, formats
+}
+
+// plainSpans returns the spans in a paragraph that don't change the look
+// of their text: no style at all, or an automatic style that only holds
+// rsids and values the text would have anyway
+func (r *formatResolver) plainSpans(paragraph *xmltree.Node) []*xmltree.Node {
+	var spans []*xmltree.Node
+	spansOf(paragraph, func(n *xmltree.Node) {
+		if r.plain(n) {
+			spans = append(spans, n)
+		}
+	})
+	return spans
+}
+
+func (r *formatResolver) plain(span *xmltree.Node) bool {
+	name, ok := span.Attr(odf.NSText, "style-name")
+	if len(span.Attrs) > 1 || (len(span.Attrs) == 1 && !ok) {
+		// text:class-names and the like
+		return false
+	}
+	if !ok {
+		return true
+	}
+
+	def := r.styles.Lookup(r.part, odf.StyleKey{Family: "text", Name: name})
+	if def == nil || !def.Automatic || def.ParentName() != "" {
+		return false
+	}
+	for _, properties := range def.Node.Elements() {
+		if !properties.Is(odf.NSStyle, "text-properties") || len(properties.Children) > 0 {
+			return false
+		}
+		for _, attr := range properties.Attrs {
+			if !odf.IsRsid(attr.Name) && attr.Value != r.property(span.Parent, attr.Name) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// legacyRules are the fixed formatting types of the original mapping file
+var legacyRules = map[string]string{
+	"Bold":        "weight=bold",
+	"Italic":      "style=italic",
+	"Bold Italic": "weight=bold style=italic",
+	"Superscript": "position=super",
+	"Subscript":   "position=sub",
+}
+
+// parsePredicates reads the predicates of a rule, or one of the original
+// formatting types
+func parsePredicates(text string) ([]odf.Predicate, error) {
+	if legacy, ok := legacyRules[text]; ok {
+		text = legacy
+	}
+	return odf.ParsePredicates(text)
+}
+
+// Rule maps spans whose formatting satisfies every predicate to a
+// character style
+type Rule struct {
+	Predicates []odf.Predicate
+	Style      string
+	Line       int
+}
+
+// Matches reports whether every predicate of the rule holds
+func (r *Rule) Matches(format odf.TextFormat) bool {
+	return odf.AllHold(r.Predicates, format)
+}
+
+func (r *Rule) String() string {
+	var predicates []string
+	for _, p := range r.Predicates {
+		predicates = append(predicates, p.String())
+	}
+	return fmt.Sprintf("%s -> %s (line %d)", strings.Join(predicates, " "), r.Style, r.Line)
+}
+
+// uncovered lists the properties of a span's direct formatting that the
+// rule's predicates say nothing about, which restyling the span loses
+func (r *Rule) uncovered(format odf.TextFormat) []string {
+	mentioned := make(map[string]bool)
+	for _, p := range r.Predicates {
+		mentioned[p.Property] = true
+	}
+	var lost []string
+	for _, property := range []struct {
+		name string
+		set  bool
+	}{
+		{"weight", format.Bold},
+		{"style", format.Italic},
+		{"position", format.Position != ""},
+		{"monospace", format.Monospace},
+		{"smallcaps", format.SmallCaps},
+		{"underline", format.Underline},
+		{"strike", format.Strike},
+		{"color", format.Color != ""},
+	} {
+		if property.set && !mentioned[property.name] {
+			lost = append(lost, property.name)
+		}
+	}
+	return lost
+}
+
+// matchRule finds the rule for a span's formatting. The rule with the most
+// predicates wins; if several rules with different styles tie for that,
+// the match is ambiguous and they are all returned instead.
+func matchRule(rules []*Rule, format odf.TextFormat) (*Rule, []*Rule) {
+	var best []*Rule
+	for _, rule := range rules {
+		if !rule.Matches(format) {
+			continue
+		}
+		switch {
+		case len(best) == 0 || len(rule.Predicates) > len(best[0].Predicates):
+			best = []*Rule{rule}
+		case len(rule.Predicates) == len(best[0].Predicates) && rule.Style != best[0].Style:
+			best = append(best, rule)
+		}
+	}
+	switch len(best) {
+	case 0:
+		return nil, nil
+	case 1:
+		return best[0], nil
+	}
+	return nil, best
+}
+
+// textProperties returns the style:text-properties attributes that give
+// text the formatting the rule asks for, for a newly created style
+func (r *Rule) textProperties() []xmltree.Attr {
+	var attrs []xmltree.Attr
+	add := func(space, local, value string) {
+		attrs = append(attrs, xmltree.Attr{Name: xml.Name{Space: space, Local: local}, Value: value})
+	}
+	for _, p := range r.Predicates {
+		switch {
+		case p.Property == "weight":
+			add(odf.NSFo, "font-weight", p.Value)
+		case p.Property == "style":
+			add(odf.NSFo, "font-style", p.Value)
+		case p.Property == "position" && p.Value == "normal":
+			add(odf.NSStyle, "text-position", "0% 100%")
+		case p.Property == "position":
+			add(odf.NSStyle, "text-position", p.Value+" 58%")
+		case p.Property == "monospace" && p.Value == "yes":
+			add(odf.NSFo, "font-family", "'Liberation Mono'")
+			add(odf.NSStyle, "font-pitch", "fixed")
+		case p.Property == "smallcaps" && p.Value == "yes":
+			add(odf.NSFo, "font-variant", "small-caps")
+		case p.Property == "smallcaps":
+			add(odf.NSFo, "font-variant", "normal")
+		case p.Property == "underline" && p.Value == "yes":
+			add(odf.NSStyle, "text-underline-style", "solid")
+			add(odf.NSStyle, "text-underline-width", "auto")
+			add(odf.NSStyle, "text-underline-color", "font-color")
+		case p.Property == "underline":
+			add(odf.NSStyle, "text-underline-style", "none")
+		case p.Property == "strike" && p.Value == "yes":
+			add(odf.NSStyle, "text-line-through-style", "solid")
+		case p.Property == "strike":
+			add(odf.NSStyle, "text-line-through-style", "none")
+		case p.Property == "color":
+			add(odf.NSFo, "color", p.Value)
+		}
+	}
+	return attrs
+}
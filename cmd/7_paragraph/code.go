@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// Code is pasted into documents as a run of paragraphs, one per line, in
+// Preformatted Text or in Standard with a monospace font. A run becomes one
+// listing, and every paragraph in it gets the same house code style.
+
+// House code styles
+const (
+	codeStyle          = "Code"
+	codeWideStyle      = "CodeWide"
+	codeAnnotatedStyle = "CodeAnnotated"
+)
+
+// sourceCodeStyles are the LibreOffice styles that mark a paragraph as code
+var sourceCodeStyles = []string{"Preformatted Text", codeStyle, codeWideStyle, codeAnnotatedStyle}
+
+// defaultCallouts matches the markers No Starch puts at the end of an
+// annotated line: the circled numbers, or <1> as typed in plain text
+const defaultCallouts = `(?:[\x{2460}-\x{2473}\x{2776}-\x{2793}]|<\d+>)\s*$`
+
+// CodeOptions controls how listings are styled
+type CodeOptions struct {
+	Width    int            // lines longer than this make a listing CodeWide
+	TabWidth int            // columns between tab stops, for measuring lines
+	Callouts *regexp.Regexp // a line matching this makes a listing CodeAnnotated
+}
+
+// monospace reports whether the font in effect at n is a fixed-width one
+func (pc *ParagraphConverter) monospace(n *xmltree.Node) bool {
+	return pc.styles.Monospace("content.xml", n, odf.DefaultLiteralFonts)
+}
+
+// isCode reports whether a paragraph is a line of code: it has one of the
+// code styles, or all of its text is in a monospace font. Empty paragraphs
+// are only code by style.
+func (pc *ParagraphConverter) isCode(paragraph *xmltree.Node) bool {
+	if paragraph.Is(odf.NSText, "h") {
+		return false
+	}
+	for _, def := range pc.chain(paragraph) {
+		for _, name := range sourceCodeStyles {
+			if hasStyle(def, name) {
+				return true
+			}
+		}
+	}
+
+	return allText(paragraph, pc.monospace)
+}
+
+// nested reports whether c, inside n, holds text of its own rather than
+// n's: a paragraph nested in n, or the frame or note that puts one there
+func nested(n, c *xmltree.Node) bool {
+	return c != n && (isParagraph(c) || c.Is(odf.NSDraw, "frame") || c.Is(odf.NSText, "note"))
+}
+
+// allText reports whether fn holds for the element around every run of
+// non-blank text in n, leaving out the text of paragraphs nested in it by
+// frames and notes. It is false if n has no text.
+func allText(n *xmltree.Node, fn func(*xmltree.Node) bool) bool {
+	text, holds := false, true
+	n.Walk(func(c *xmltree.Node) bool {
+		if nested(n, c) {
+			return false
+		}
+		if c.Kind == xmltree.TextNode && strings.TrimSpace(c.Data) != "" {
+			text = true
+			holds = holds && fn(c.Parent)
+		}
+		return c.Kind == xmltree.ElementNode
+	})
+	return text && holds
+}
+
+// inlineText returns the text of n, leaving out that of paragraphs nested
+// in it by frames and notes
+func inlineText(n *xmltree.Node) string {
+	var text strings.Builder
+	n.Walk(func(c *xmltree.Node) bool {
+		if nested(n, c) {
+			return false
+		}
+		if c.Kind == xmltree.TextNode {
+			text.WriteString(c.Data)
+		}
+		return c.Kind == xmltree.ElementNode
+	})
+	return text.String()
+}
+
+// listings groups the code paragraphs of the body into runs of adjacent
+// siblings. An empty paragraph between two lines of code is a blank line
+// of the listing.
+func (pc *ParagraphConverter) listings() [][]*xmltree.Node {
+	var runs [][]*xmltree.Node
+	var run, blanks []*xmltree.Node
+	var last *xmltree.Node
+	for _, paragraph := range pc.bodyParagraphs() {
+		if last != nil && nextParagraph(last) != paragraph {
+			if len(run) > 0 {
+				runs = append(runs, run)
+			}
+			run, blanks = nil, nil
+		}
+		last = paragraph
+		switch {
+		case pc.isCode(paragraph):
+			run = append(append(run, blanks...), paragraph)
+			blanks = nil
+		case len(run) > 0 && strings.TrimSpace(paragraph.Text()) == "" && len(paragraph.Elements()) == 0:
+			blanks = append(blanks, paragraph)
+		default:
+			if len(run) > 0 {
+				runs = append(runs, run)
+			}
+			run, blanks = nil, nil
+		}
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// nextParagraph returns the paragraph that follows another as its next
+// sibling, skipping soft page breaks and whitespace, or nil
+func nextParagraph(paragraph *xmltree.Node) *xmltree.Node {
+	siblings := paragraph.Parent.Children
+	for i := paragraph.Index() + 1; i < len(siblings); i++ {
+		n := siblings[i]
+		switch {
+		case n.Kind == xmltree.TextNode && strings.TrimSpace(n.Data) == "":
+		case n.Is(odf.NSText, "soft-page-break"):
+		case isParagraph(n):
+			return n
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// codeLines returns the lines of a code paragraph as they are displayed,
+// with text:s expanded, tabs taken to the next tab stop and text:line-break
+// starting a new line
+func codeLines(paragraph *xmltree.Node, tabWidth int) []string {
+	lines := []string{""}
+	var walk func(n *xmltree.Node)
+	walk = func(n *xmltree.Node) {
+		for _, c := range n.Children {
+			last := len(lines) - 1
+			switch {
+			case c.Kind == xmltree.TextNode:
+				lines[last] += c.Data
+			case c.Is(odf.NSText, "s"):
+				lines[last] += strings.Repeat(" ", spaceCount(c))
+			case c.Is(odf.NSText, "tab"):
+				column := utf8.RuneCountInString(lines[last])
+				lines[last] += strings.Repeat(" ", tabWidth-column%tabWidth)
+			case c.Is(odf.NSText, "line-break"):
+				lines = append(lines, "")
+			case c.Is(odf.NSText, "span"), c.Is(odf.NSText, "a"):
+				walk(c)
+			}
+		}
+	}
+	walk(paragraph)
+	return lines
+}
+
+// spaceCount returns the number of spaces a text:s stands for
+func spaceCount(s *xmltree.Node) int {
+	if count, err := strconv.Atoi(s.AttrValue(odf.NSText, "c")); err == nil && count > 0 {
+		return count
+	}
+	return 1
+}
+
+// ConvertCode gives each listing Code, CodeWide if a line is longer than
+// the width allowed, or CodeAnnotated if a line ends in a callout, and
+// rewrites the whitespace of every line so that it survives
+func (pc *ParagraphConverter) ConvertCode(options CodeOptions) error {
+	runs := pc.listings()
+	for _, run := range runs {
+		wide, annotated := false, false
+		for _, paragraph := range run {
+			for _, line := range codeLines(paragraph, options.TabWidth) {
+				line = strings.TrimRight(line, " ")
+				wide = wide || utf8.RuneCountInString(line) > options.Width
+				annotated = annotated || options.Callouts.MatchString(line)
+			}
+		}
+
+		code, err := pc.ensureStyle(codeStyle, pc.namedStyle(run[0]), func(style *xmltree.Node) {
+			properties := xmltree.NewElement(xml.Name{Space: odf.NSStyle, Local: "text-properties"})
+			properties.SetAttr(odf.NSFo, "font-family", "'Liberation Mono'")
+			properties.SetAttr(odf.NSStyle, "font-pitch", "fixed")
+			style.AppendChild(properties)
+		})
+		if err != nil {
+			return err
+		}
+		name := code
+		switch {
+		case annotated:
+			if wide {
+				pc.report.Warn("annotated listing has lines over %d columns: %s", options.Width, excerpt(run[0]))
+			}
+			name, err = pc.ensureStyle(codeAnnotatedStyle, code, nil)
+		case wide:
+			name, err = pc.ensureStyle(codeWideStyle, code, nil)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, paragraph := range run {
+			pc.setStyle(paragraph, name)
+			preserveSpaces(paragraph)
+		}
+	}
+	fmt.Printf("Converted %d code listings\n", len(runs))
+	return pc.refresh()
+}
+
+// preserveSpaces rewrites the whitespace of a line of code so that ODF
+// doesn't collapse it. Only the first of several spaces may be written as
+// a space; the rest, and spaces at the start of the line or after a tab or
+// line break, must be a text:s with the count in text:c. Tabs and newlines
+// pasted into the text become text:tab and text:line-break, and text:s
+// elements next to each other are merged.
+func preserveSpaces(paragraph *xmltree.Node) {
+	collapsing := true // a space here would be dropped
+	var rewrite func(n *xmltree.Node)
+	rewrite = func(n *xmltree.Node) {
+		var children []*xmltree.Node
+		var text strings.Builder
+		spaces := 0
+		flushText := func() {
+			if text.Len() > 0 {
+				children = append(children, xmltree.NewText(text.String()))
+				text.Reset()
+			}
+		}
+		flushSpaces := func() {
+			if spaces == 0 {
+				return
+			}
+			if !collapsing {
+				text.WriteByte(' ')
+				spaces--
+			}
+			flushText()
+			if spaces > 0 {
+				s := xmltree.NewElement(xml.Name{Space: odf.NSText, Local: "s"})
+				if spaces > 1 {
+					s.SetAttr(odf.NSText, "c", strconv.Itoa(spaces))
+				}
+				children = append(children, s)
+			}
+			spaces = 0
+			collapsing = true
+		}
+		element := func(local string) *xmltree.Node {
+			flushSpaces()
+			flushText()
+			collapsing = true
+			return xmltree.NewElement(xml.Name{Space: odf.NSText, Local: local})
+		}
+
+		for _, c := range n.Children {
+			switch {
+			case c.Kind == xmltree.TextNode:
+				for _, r := range c.Data {
+					switch r {
+					case ' ':
+						spaces++
+					case '\t':
+						children = append(children, element("tab"))
+					case '\n':
+						children = append(children, element("line-break"))
+					case '\r':
+					default:
+						flushSpaces()
+						text.WriteRune(r)
+						collapsing = false
+					}
+				}
+			case c.Is(odf.NSText, "s"):
+				spaces += spaceCount(c)
+			case c.Is(odf.NSText, "tab"), c.Is(odf.NSText, "line-break"):
+				flushSpaces()
+				flushText()
+				children = append(children, c)
+				collapsing = true
+			case c.Is(odf.NSText, "span"), c.Is(odf.NSText, "a"):
+				flushSpaces()
+				flushText()
+				rewrite(c)
+				children = append(children, c)
+			default:
+				flushSpaces()
+				flushText()
+				children = append(children, c)
+			}
+		}
+		flushSpaces()
+		flushText()
+
+		for _, c := range children {
+			c.Parent = n
+		}
+		n.Children = children
+	}
+	rewrite(paragraph)
+}
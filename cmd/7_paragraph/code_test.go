@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// parseParagraph parses the content of a text:p
+func parseParagraph(t *testing.T, inner string) *xmltree.Node {
+	t.Helper()
+	doc, err := xmltree.Parse([]byte(`<text:p xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` + inner + `</text:p>`))
+	if err != nil {
+		t.Fatalf("parsing paragraph: %v", err)
+	}
+	return doc.Root
+}
+
+// innerXML serialises the children of n
+func innerXML(n *xmltree.Node) string {
+	var b strings.Builder
+	for _, c := range n.Children {
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
+
+func TestPreserveSpaces(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"single spaces stay", `a b c`, `a b c`},
+		{"leading spaces", `    x`, `<text:s text:c="4"/>x`},
+		{"two spaces", `a  b`, `a <text:s/>b`},
+		{"run of spaces", `a     b`, `a <text:s text:c="4"/>b`},
+		{"trailing spaces", `a   `, `a <text:s text:c="2"/>`},
+		{"already preserved", `<text:s text:c="4"/>x = <text:s/>1`, `<text:s text:c="4"/>x = <text:s/>1`},
+		{"spaces next to text:s are merged", `a <text:s text:c="2"/> b`, `a <text:s text:c="3"/>b`},
+		{"pasted tab", "a\tb", `a<text:tab/>b`},
+		{"space after a tab", "\t x", `<text:tab/><text:s/>x`},
+		{"space after text:tab", `<text:tab/> x`, `<text:tab/><text:s/>x`},
+		{"pasted newline", "a\n  b", `a<text:line-break/><text:s text:c="2"/>b`},
+		{"carriage return dropped", "a\r\nb", `a<text:line-break/>b`},
+		{
+			"spaces across a span boundary",
+			`<text:span text:style-name="T1">a </text:span> b`,
+			`<text:span text:style-name="T1">a </text:span><text:s/>b`,
+		},
+		{
+			"indent inside a span",
+			`<text:span text:style-name="T1">  if x:</text:span>`,
+			`<text:span text:style-name="T1"><text:s text:c="2"/>if x:</text:span>`,
+		},
+		{"empty", ``, ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paragraph := parseParagraph(t, tt.in)
+			preserveSpaces(paragraph)
+			if got := innerXML(paragraph); got != tt.want {
+				t.Errorf("preserveSpaces(%q) = %s\nwant %s", tt.in, got, tt.want)
+			}
+
+			// Doing it again changes nothing
+			preserveSpaces(paragraph)
+			if got := innerXML(paragraph); got != tt.want {
+				t.Errorf("second pass gave %s", got)
+			}
+		})
+	}
+}
+
+func TestCodeLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		tabWidth int
+		want     []string
+	}{
+		{"plain", `x = 1`, 8, []string{"x = 1"}},
+		{"text:s", `<text:s text:c="4"/>x<text:s/>=`, 8, []string{"    x ="}},
+		{"tab to the next stop", `ab<text:tab/>c`, 4, []string{"ab  c"}},
+		{"tab at a stop", `abcd<text:tab/>e`, 4, []string{"abcd    e"}},
+		{"line breaks", `a<text:line-break/><text:s text:c="2"/>b`, 8, []string{"a", "  b"}},
+		{"spans", `<text:span text:style-name="T1">if</text:span> x`, 8, []string{"if x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := codeLines(parseParagraph(t, tt.in), tt.tabWidth); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("codeLines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
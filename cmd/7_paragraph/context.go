@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// Whether a paragraph of running text is Body or BodyContinued depends on
+// what comes before it, which renaming can't see. The body is read as a
+// sequence of blocks, each of a kind: heading, list, table, code, figure,
+// note, quote, body or paragraph for anything else. A rule gives a body
+// paragraph a style by the kinds or styles of the one or two blocks before
+// it.
+
+// ContextRule restyles a paragraph matching Paragraph whose previous block
+// matches Previous and the block before that Before. Each pattern is a
+// kind, a style name or * for anything; "start" matches the absence of a
+// block at the start of the document.
+type ContextRule struct {
+	Previous  string
+	Before    string
+	Paragraph string
+	Style     string
+	Line      int
+}
+
+// BlockKind lists the paragraph styles that make up a kind of block
+type BlockKind struct {
+	Kind   string
+	Styles []string
+}
+
+// ContextRules holds the rules in file order, the first that matches being
+// used, and the paragraph styles that make up each kind of block, in the
+// order the kinds first appear in the file
+type ContextRules struct {
+	Rules []ContextRule
+	Kinds []BlockKind
+}
+
+// addKind lists a style under a kind
+func (rules *ContextRules) addKind(kind, style string) {
+	for i := range rules.Kinds {
+		if rules.Kinds[i].Kind == kind {
+			rules.Kinds[i].Styles = append(rules.Kinds[i].Styles, style)
+			return
+		}
+	}
+	rules.Kinds = append(rules.Kinds, BlockKind{Kind: kind, Styles: []string{style}})
+}
+
+// LoadContextRules reads the CSV rules file. Lines of the form
+// "kind,note,Note" say that paragraphs in the Note style are note blocks;
+// the others are rules: previous block, block before that, paragraph,
+// style. A style listed under two kinds belongs to the one listed first.
+// Lines starting with # are comments.
+func LoadContextRules(filename string) (*ContextRules, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	lineCount := 0
+
+	rules := &ContextRules{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", lineCount+1, err)
+		}
+
+		lineCount++
+
+		// Skip comment lines
+		if len(record) > 0 && strings.HasPrefix(record[0], "#") {
+			continue
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+
+		if record[0] == "kind" {
+			if len(record) < 3 || record[1] == "" || record[2] == "" {
+				log.Printf("Warning: skipping line %d - a kind needs a name and a style", lineCount)
+				continue
+			}
+			rules.addKind(record[1], record[2])
+			continue
+		}
+
+		// Ensure we have all four columns
+		if len(record) < 4 || record[3] == "" {
+			log.Printf("Warning: skipping line %d - insufficient columns", lineCount)
+			continue
+		}
+		rules.Rules = append(rules.Rules, ContextRule{
+			Previous:  record[0],
+			Before:    record[1],
+			Paragraph: record[2],
+			Style:     record[3],
+			Line:      lineCount,
+		})
+	}
+
+	fmt.Printf("Loaded %d context rules from %s\n", len(rules.Rules), filename)
+	return rules, nil
+}
+
+// block is one element of the body's flow of text
+type block struct {
+	node *xmltree.Node
+	kind string
+	defs []*odf.StyleDef // the paragraph's style and its ancestors
+}
+
+// matches reports whether a block matches a rule pattern
+func (b *block) matches(pattern string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case b == nil:
+		return pattern == "start"
+	case pattern == b.kind:
+		return true
+	}
+	for _, def := range b.defs {
+		if hasStyle(def, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// blocks returns the blocks of the body in order, reading through sections
+// but not into lists, tables or frames. Empty paragraphs aren't blocks.
+func (pc *ParagraphConverter) blocks(rules *ContextRules) []*block {
+	var found []*block
+	var visit func(n *xmltree.Node)
+	visit = func(n *xmltree.Node) {
+		for _, c := range n.Elements() {
+			switch {
+			case c.Is(odf.NSText, "section"), c.Is(odf.NSOffice, "text"):
+				visit(c)
+			case c.Is(odf.NSText, "list"), c.Is(odf.NSText, "numbered-paragraph"):
+				found = append(found, &block{node: c, kind: "list"})
+			case c.Is(odf.NSTable, "table"):
+				found = append(found, &block{node: c, kind: "table"})
+			case isParagraph(c):
+				if strings.TrimSpace(c.Text()) == "" && len(c.Elements()) == 0 {
+					continue
+				}
+				b := &block{node: c, defs: pc.chain(c)}
+				b.kind = pc.blockKind(b, rules)
+				found = append(found, b)
+			}
+		}
+	}
+	if body := pc.content.Child(odf.NSOffice, "body"); body != nil {
+		visit(body)
+	}
+	return found
+}
+
+// blockKind classifies a paragraph: as a heading or code by what it is,
+// then by the first kind the rules file gives the nearest style it has or
+// inherits from, and last as a figure if it holds only a frame
+func (pc *ParagraphConverter) blockKind(b *block, rules *ContextRules) string {
+	switch {
+	case b.node.Is(odf.NSText, "h"):
+		return "heading"
+	case pc.isCode(b.node):
+		return "code"
+	}
+	for _, def := range b.defs {
+		for _, kind := range rules.Kinds {
+			for _, style := range kind.Styles {
+				if hasStyle(def, style) {
+					return kind.Kind
+				}
+			}
+		}
+	}
+	if b.node.Child(odf.NSDraw, "frame") != nil && strings.TrimSpace(ownText(b.node)) == "" {
+		return "figure"
+	}
+	return "paragraph"
+}
+
+// ownText returns the text of a paragraph outside any frames in it
+func ownText(paragraph *xmltree.Node) string {
+	var text strings.Builder
+	paragraph.Walk(func(n *xmltree.Node) bool {
+		if n.Is(odf.NSDraw, "frame") {
+			return false
+		}
+		if n.Kind == xmltree.TextNode {
+			text.WriteString(n.Data)
+		}
+		return n.Kind == xmltree.ElementNode
+	})
+	return text.String()
+}
+
+// ConvertContext gives each paragraph the style of the first rule its
+// context matches
+func (pc *ParagraphConverter) ConvertContext(rules *ContextRules) error {
+	blocks := pc.blocks(rules)
+
+	// Match every paragraph against the blocks as they were, so that one
+	// restyled paragraph doesn't change the context of the next
+	restyle := make(map[*block]ContextRule)
+	for i, b := range blocks {
+		if !isParagraph(b.node) {
+			continue
+		}
+		var previous, before *block
+		if i > 0 {
+			previous = blocks[i-1]
+		}
+		if i > 1 {
+			before = blocks[i-2]
+		}
+		for _, rule := range rules.Rules {
+			if b.matches(rule.Paragraph) && previous.matches(rule.Previous) && before.matches(rule.Before) {
+				restyle[b] = rule
+				break
+			}
+		}
+	}
+
+	changed := 0
+	for _, b := range blocks {
+		rule, ok := restyle[b]
+		if !ok {
+			continue
+		}
+		name, err := pc.ensureStyle(rule.Style, pc.namedStyle(b.node), nil)
+		if err != nil {
+			return err
+		}
+		if pc.setStyle(b.node, name) {
+			changed++
+		}
+	}
+	fmt.Printf("Applied context rules to %d paragraphs\n", changed)
+	return pc.refresh()
+}
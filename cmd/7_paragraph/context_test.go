@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBlockKinds(t *testing.T) {
+	const rules = `#Note is listed twice; the first kind wins
+kind,quote,Note
+kind,body,Text body
+kind,note,Note
+kind,note,Aside
+kind,broken
+*,*,body,Body
+`
+	filename := filepath.Join(t.TempDir(), "context.txt")
+	if err := os.WriteFile(filename, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadContextRules(filename)
+	if err != nil {
+		t.Fatalf("LoadContextRules: %v", err)
+	}
+	wantKinds := []BlockKind{{"quote", []string{"Note"}}, {"body", []string{"Text body"}}, {"note", []string{"Note", "Aside"}}}
+	if !reflect.DeepEqual(set.Kinds, wantKinds) {
+		t.Errorf("Kinds = %v, want %v", set.Kinds, wantKinds)
+	}
+
+	pc := testConverter(t,
+		`<office:styles>`+
+			`<style:style style:name="Text_20_body" style:display-name="Text body" style:family="paragraph"/>`+
+			`<style:style style:name="Note" style:family="paragraph"/>`+
+			`<style:style style:name="Aside" style:family="paragraph"/>`+
+			`<style:style style:name="Aside_20_Note" style:display-name="Aside Note" style:family="paragraph" style:parent-style-name="Note"/>`+
+			`<style:style style:name="Other" style:family="paragraph" style:parent-style-name="Aside"/>`+
+			`</office:styles>`,
+		`<office:automatic-styles>`+
+			`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Note"/>`+
+			`</office:automatic-styles>`+
+			`<office:body><office:text>`+
+			`<text:h text:outline-level="1">Heading</text:h>`+
+			`<text:p text:style-name="Note">listed under quote and note</text:p>`+
+			`<text:p text:style-name="P1">automatic style based on Note</text:p>`+
+			`<text:p text:style-name="Aside">note only</text:p>`+
+			`<text:p text:style-name="Other">inherits from Aside</text:p>`+
+			`<text:p text:style-name="Aside_20_Note">inherits from Note</text:p>`+
+			`<text:p text:style-name="Text_20_body">body</text:p>`+
+			`<text:p/>`+
+			`<text:p>unlisted</text:p>`+
+			`<text:list><text:list-item><text:p>item</text:p></text:list-item></text:list>`+
+			`</office:text></office:body>`)
+
+	want := []string{"heading", "quote", "quote", "note", "note", "quote", "body", "paragraph", "list"}
+	// Classify several times, as a map of kinds would vary from run to run
+	for run := 0; run < 20; run++ {
+		var got []string
+		for _, b := range pc.blocks(set) {
+			got = append(got, b.kind)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("kinds = %q, want %q", got, want)
+		}
+	}
+}
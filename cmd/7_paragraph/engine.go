@@ -0,0 +1,698 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// The other transforms each know one thing about paragraphs. A rules file
+// lets editors write the whole mapping to the house styles without
+// recompiling: it names the built-in transforms to run first, and then
+// each rule picks out paragraphs or spans by their style, where they are,
+// their neighbours, their text and their formatting, and says what to do
+// with them.
+
+// transforms are the built-in conversions a rules file can run before its
+// rules, in the order the all command runs them
+var transforms = []string{"headings", "code", "lists", "context"}
+
+// Actions a rule can take
+const (
+	actionStyle  = "style"  // give the element the style in the argument
+	actionWrap   = "wrap"   // put its content in a span of the character style in the argument
+	actionUnwrap = "unwrap" // replace a span by its content, or every span in a paragraph
+	actionSplit  = "split"  // split a paragraph at line breaks, or before each match of the argument
+	actionDelete = "delete" // remove the element and everything in it
+)
+
+// Condition tests one thing about a paragraph or span, written key=value,
+// or key!=value for the opposite:
+//
+//	style=<name>      the element's style is or inherits from the style
+//	ancestor=<qname>  the element is inside an element such as text:list-item
+//	previous=<value>  the sibling before it has the style, or is the element
+//	next=<value>      given as a qname; none means there isn't one, text a
+//	                  run of text
+//	text=<regexp>     its text, without that of notes and frames, matches
+//	format=<tests>    all its text has the formatting, in the predicates of
+//	                  cmd/6_character: weight=bold style=italic position=sub
+//	                  monospace smallcaps underline strike (or =no) color=#rrggbb
+//	direct=<tests>    a span adds formatting to the text around it, and what
+//	                  it adds passes the tests, as cmd/6_character classifies
+//	                  spans
+//
+// Qualified names use the usual ODF prefixes, and match elements by
+// namespace whatever prefixes the document binds.
+type Condition struct {
+	Key     string
+	Value   string
+	Negate  bool
+	re      *regexp.Regexp  // for text
+	format  []odf.Predicate // for format and direct
+	element xml.Name        // for ancestor, and previous or next given a qname
+}
+
+// parseCondition reads a key=value or key!=value condition
+func parseCondition(text string) (Condition, error) {
+	key, value, found := strings.Cut(text, "=")
+	if !found {
+		return Condition{}, fmt.Errorf("condition '%s' has no =", text)
+	}
+	condition := Condition{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)}
+	if strings.HasSuffix(condition.Key, "!") {
+		condition.Key = strings.TrimSpace(strings.TrimSuffix(condition.Key, "!"))
+		condition.Negate = true
+	}
+
+	switch condition.Key {
+	case "style", "ancestor", "previous", "next":
+		if condition.Value == "" {
+			return Condition{}, fmt.Errorf("%s needs a value", condition.Key)
+		}
+		if condition.Key == "ancestor" || strings.Contains(condition.Value, ":") {
+			element, err := odf.ParseQName(condition.Value)
+			if err != nil {
+				return Condition{}, fmt.Errorf("bad %s: %w", condition.Key, err)
+			}
+			condition.element = element
+		}
+	case "text":
+		re, err := regexp.Compile(condition.Value)
+		if err != nil {
+			return Condition{}, fmt.Errorf("bad text pattern: %w", err)
+		}
+		condition.re = re
+	case "format", "direct":
+		format, err := odf.ParsePredicates(condition.Value)
+		if err != nil {
+			return Condition{}, fmt.Errorf("bad format: %w", err)
+		}
+		condition.format = format
+	default:
+		return Condition{}, fmt.Errorf("unknown condition '%s'", condition.Key)
+	}
+	return condition, nil
+}
+
+// Rule applies an action to the paragraphs or spans that meet all of its
+// conditions
+type Rule struct {
+	Priority   int
+	Target     string // "paragraph" or "span"
+	Action     string
+	Argument   string
+	Conditions []Condition
+	Line       int
+	split      *regexp.Regexp // where split breaks a paragraph, nil for line breaks
+}
+
+func (r *Rule) String() string {
+	action := r.Action
+	if r.Argument != "" {
+		action += " " + r.Argument
+	}
+	return fmt.Sprintf("line %d (priority %d): %s", r.Line, r.Priority, action)
+}
+
+// RuleSet holds the built-in transforms to run, in order, and then the
+// rules, highest priority first. With AllMatch every rule that matches an
+// element is applied, in that order; otherwise only the first.
+type RuleSet struct {
+	Transforms []string
+	Rules      []*Rule
+	AllMatch   bool
+}
+
+// LoadRuleSet reads the CSV rules file. A line "mode,all" or "mode,first"
+// sets how many rules apply to an element, and "transforms," followed by
+// names from headings, code, lists and context the transforms to run
+// first; the others are rules: priority, paragraph or span, action,
+// argument, then any number of conditions. Rules with the same priority
+// keep their order in the file. Lines starting with # are comments.
+func LoadRuleSet(filename string) (*RuleSet, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	lineCount := 0
+
+	rules := &RuleSet{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", lineCount+1, err)
+		}
+
+		lineCount++
+
+		// Skip comment lines
+		if len(record) > 0 && strings.HasPrefix(record[0], "#") {
+			continue
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+
+		if record[0] == "mode" {
+			if len(record) < 2 || (record[1] != "first" && record[1] != "all") {
+				log.Printf("Warning: skipping line %d - mode must be first or all", lineCount)
+				continue
+			}
+			rules.AllMatch = record[1] == "all"
+			continue
+		}
+		if record[0] == "transforms" {
+			names, err := parseTransforms(record[1:])
+			if err != nil {
+				log.Printf("Warning: skipping line %d - %v", lineCount, err)
+				continue
+			}
+			rules.Transforms = append(rules.Transforms, names...)
+			continue
+		}
+
+		rule, err := parseRule(record)
+		if err != nil {
+			log.Printf("Warning: skipping line %d - %v", lineCount, err)
+			continue
+		}
+		rule.Line = lineCount
+		rules.Rules = append(rules.Rules, rule)
+	}
+
+	sort.SliceStable(rules.Rules, func(i, j int) bool {
+		return rules.Rules[i].Priority > rules.Rules[j].Priority
+	})
+	mode := "first match"
+	if rules.AllMatch {
+		mode = "all matches"
+	}
+	fmt.Printf("Loaded %d rules from %s, applying %s\n", len(rules.Rules), filename, mode)
+	return rules, nil
+}
+
+// parseTransforms checks the names on a transforms line
+func parseTransforms(fields []string) ([]string, error) {
+	var names []string
+	for _, name := range fields {
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, transform := range transforms {
+			known = known || transform == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown transform '%s'", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// parseRule reads the columns of a rule
+func parseRule(record []string) (*Rule, error) {
+	if len(record) < 4 {
+		return nil, fmt.Errorf("insufficient columns")
+	}
+	priority, err := strconv.Atoi(record[0])
+	if err != nil {
+		return nil, fmt.Errorf("bad priority '%s'", record[0])
+	}
+	rule := &Rule{Priority: priority, Target: record[1], Action: record[2], Argument: record[3]}
+	if rule.Target != "paragraph" && rule.Target != "span" {
+		return nil, fmt.Errorf("target must be paragraph or span, got '%s'", rule.Target)
+	}
+
+	switch rule.Action {
+	case actionStyle, actionWrap:
+		if rule.Argument == "" {
+			return nil, fmt.Errorf("%s needs a style name", rule.Action)
+		}
+	case actionSplit:
+		if rule.Target != "paragraph" {
+			return nil, fmt.Errorf("only paragraphs can be split")
+		}
+		if rule.Argument != "" {
+			if rule.split, err = regexp.Compile(rule.Argument); err != nil {
+				return nil, fmt.Errorf("bad split pattern: %w", err)
+			}
+		}
+	case actionUnwrap, actionDelete:
+	default:
+		return nil, fmt.Errorf("unknown action '%s'", rule.Action)
+	}
+
+	for _, field := range record[4:] {
+		if field == "" {
+			continue
+		}
+		condition, err := parseCondition(field)
+		if err != nil {
+			return nil, err
+		}
+		if condition.Key == "direct" && rule.Target != "span" {
+			return nil, fmt.Errorf("only spans have direct formatting")
+		}
+		rule.Conditions = append(rule.Conditions, condition)
+	}
+	return rule, nil
+}
+
+// elementChain returns the style of a paragraph or span followed by the
+// styles it inherits from
+func (pc *ParagraphConverter) elementChain(n *xmltree.Node) []*odf.StyleDef {
+	if isParagraph(n) {
+		return pc.chain(n)
+	}
+	var defs []*odf.StyleDef
+	seen := make(map[*odf.StyleDef]bool)
+	key := odf.StyleKey{Family: "text", Name: n.AttrValue(odf.NSText, "style-name")}
+	for def := pc.styles.Lookup("content.xml", key); def != nil && !seen[def]; def = pc.styles.Parent(def) {
+		seen[def] = true
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// styled reports whether an element has or inherits from a style
+func (pc *ParagraphConverter) styled(n *xmltree.Node, name string) bool {
+	for _, def := range pc.elementChain(n) {
+		if hasStyle(def, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sibling returns the node next to n in the given direction, skipping
+// blank text and soft page breaks, or nil
+func sibling(n *xmltree.Node, step int) *xmltree.Node {
+	siblings := n.Parent.Children
+	for i := n.Index() + step; i >= 0 && i < len(siblings); i += step {
+		c := siblings[i]
+		switch {
+		case c.Kind == xmltree.TextNode && strings.TrimSpace(c.Data) == "":
+		case c.Is(odf.NSText, "soft-page-break"):
+		case c.Kind == xmltree.TextNode, c.Kind == xmltree.ElementNode:
+			return c
+		}
+	}
+	return nil
+}
+
+// siblingMatches reports whether a neighbour found by sibling matches a
+// previous or next condition
+func (pc *ParagraphConverter) siblingMatches(n *xmltree.Node, c Condition) bool {
+	switch {
+	case n == nil:
+		return c.Value == "none"
+	case n.Kind == xmltree.TextNode:
+		return c.Value == "text"
+	case c.element.Local != "":
+		return n.Is(c.element.Space, c.element.Local)
+	case isParagraph(n), n.Is(odf.NSText, "span"):
+		return pc.styled(n, c.Value)
+	}
+	return false
+}
+
+// holds reports whether an element meets a condition
+func (pc *ParagraphConverter) holds(n *xmltree.Node, c Condition) bool {
+	result := false
+	switch c.Key {
+	case "style":
+		result = pc.styled(n, c.Value)
+	case "ancestor":
+		for a := n.Parent; a != nil && !result; a = a.Parent {
+			result = a.Is(c.element.Space, c.element.Local)
+		}
+	case "previous":
+		result = pc.siblingMatches(sibling(n, -1), c)
+	case "next":
+		result = pc.siblingMatches(sibling(n, 1), c)
+	case "text":
+		result = c.re.MatchString(inlineText(n))
+	case "format":
+		result = allText(n, func(at *xmltree.Node) bool {
+			return odf.AllHold(c.format, pc.styles.FormatAt("content.xml", at, odf.DefaultLiteralFonts))
+		})
+	case "direct":
+		format := pc.styles.DirectFormat("content.xml", n, odf.DefaultLiteralFonts)
+		result = format != (odf.TextFormat{}) && odf.AllHold(c.format, format)
+	}
+	return result != c.Negate
+}
+
+// matches reports whether an element is a target of a rule and meets all
+// of its conditions
+func (pc *ParagraphConverter) matches(n *xmltree.Node, rule *Rule) bool {
+	if isParagraph(n) != (rule.Target == "paragraph") {
+		return false
+	}
+	for _, c := range rule.Conditions {
+		if !pc.holds(n, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// targets returns the paragraphs and spans of the body in document order,
+// leaving out the generated text of tables of contents and indexes
+func (pc *ParagraphConverter) targets() []*xmltree.Node {
+	var found []*xmltree.Node
+	body := pc.content.Child(odf.NSOffice, "body")
+	if body == nil {
+		return nil
+	}
+	body.Walk(func(n *xmltree.Node) bool {
+		if n.Is(odf.NSText, "index-body") {
+			return false
+		}
+		if isParagraph(n) || n.Is(odf.NSText, "span") {
+			found = append(found, n)
+		}
+		return n.Kind == xmltree.ElementNode
+	})
+	return found
+}
+
+// paragraphOf returns the paragraph n is or is in, if any
+func paragraphOf(n *xmltree.Node) *xmltree.Node {
+	for ; n != nil; n = n.Parent {
+		if isParagraph(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// attached reports whether n is still in content.xml, and not in something
+// an earlier rule deleted or unwrapped
+func (pc *ParagraphConverter) attached(n *xmltree.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n == pc.content {
+			return true
+		}
+	}
+	return false
+}
+
+// RunTransform runs one of the built-in transforms, reading the files it
+// needs as the options say
+func (pc *ParagraphConverter) RunTransform(name string, options Options) error {
+	switch name {
+	case "headings":
+		levels, err := LoadHeadingLevels(options.Headings)
+		if err != nil {
+			return err
+		}
+		return pc.ConvertHeadings(levels)
+	case "code":
+		return pc.ConvertCode(options.Code)
+	case "lists":
+		return pc.ConvertLists()
+	case "context":
+		rules, err := LoadContextRules(options.Context)
+		if err != nil {
+			return err
+		}
+		return pc.ConvertContext(rules)
+	}
+	return fmt.Errorf("unknown transform '%s'", name)
+}
+
+// ApplyRules runs the transforms of a rule set, matches every paragraph
+// and span against the rules and then applies the actions of the rules
+// that matched. With options.Trace set, it shows which rules fired for
+// each paragraph and span, and the paragraphs no rule matched.
+func (pc *ParagraphConverter) ApplyRules(rules *RuleSet, options Options) error {
+	for _, name := range rules.Transforms {
+		if err := pc.RunTransform(name, options); err != nil {
+			return err
+		}
+	}
+	if len(rules.Rules) == 0 {
+		return nil
+	}
+
+	// Match every element against the document as it was, so that one
+	// rule's action doesn't change what the next rule sees
+	targets := pc.targets()
+	fired := make(map[*xmltree.Node][]*Rule)
+	for _, n := range targets {
+		for _, rule := range rules.Rules {
+			if pc.matches(n, rule) {
+				fired[n] = append(fired[n], rule)
+				if !rules.AllMatch {
+					break
+				}
+			}
+		}
+	}
+
+	if options.Trace {
+		fmt.Println("\n=== Rule Trace ===")
+		for _, n := range targets {
+			kind := "span"
+			if isParagraph(n) {
+				kind = "paragraph"
+			}
+			if len(fired[n]) == 0 {
+				if kind == "paragraph" {
+					fmt.Printf("%s %s: no rule\n", kind, excerpt(n))
+				}
+				continue
+			}
+			for _, rule := range fired[n] {
+				fmt.Printf("%s %s: %s\n", kind, excerpt(n), rule)
+			}
+		}
+		fmt.Println()
+	}
+
+	counts := make(map[string]int)
+	var restyled []*xmltree.Node
+	for _, n := range targets {
+		for _, rule := range fired[n] {
+			if !pc.attached(n) {
+				break
+			}
+			if err := pc.apply(n, rule); err != nil {
+				return fmt.Errorf("failed to apply the rule on line %d: %w", rule.Line, err)
+			}
+			counts[rule.Action]++
+			if rule.Action == actionWrap || (rule.Action == actionStyle && !isParagraph(n)) {
+				restyled = append(restyled, n)
+			}
+		}
+	}
+
+	// Spans that ended up in the same character style are joined, as the
+	// character style conversion does, rather than left as fragments
+	for _, n := range restyled {
+		if paragraph := paragraphOf(n); paragraph != nil && pc.attached(paragraph) {
+			odf.MergeSpans(paragraph)
+			paragraph.Normalize()
+		}
+	}
+	for _, action := range []string{actionStyle, actionWrap, actionUnwrap, actionSplit, actionDelete} {
+		if counts[action] > 0 {
+			fmt.Printf("Applied %s to %d elements\n", action, counts[action])
+		}
+	}
+	return pc.refresh()
+}
+
+// apply carries out a rule's action on an element
+func (pc *ParagraphConverter) apply(n *xmltree.Node, rule *Rule) error {
+	switch rule.Action {
+	case actionStyle:
+		if !isParagraph(n) {
+			name, err := pc.ensureFamilyStyle("text", rule.Argument, "", nil)
+			if err != nil {
+				return err
+			}
+			n.SetAttr(odf.NSText, "style-name", name)
+			return nil
+		}
+		name, err := pc.ensureStyle(rule.Argument, pc.namedStyle(n), nil)
+		if err != nil {
+			return err
+		}
+		if pc.styleOf(n) == nil {
+			// Restyled by an earlier rule into a style not indexed yet
+			if err := pc.refresh(); err != nil {
+				return err
+			}
+		}
+		pc.setStyle(n, name)
+	case actionWrap:
+		name, err := pc.ensureFamilyStyle("text", rule.Argument, "", nil)
+		if err != nil {
+			return err
+		}
+		span := xmltree.NewElement(xml.Name{Space: odf.NSText, Local: "span"})
+		span.SetAttr(odf.NSText, "style-name", name)
+		for _, c := range n.Children {
+			span.AppendChild(c)
+		}
+		n.Children = nil
+		n.AppendChild(span)
+	case actionUnwrap:
+		if !isParagraph(n) {
+			parent := n.Parent
+			n.Unwrap()
+			parent.Normalize()
+			return nil
+		}
+		var spans []*xmltree.Node
+		n.Walk(func(c *xmltree.Node) bool {
+			if nested(n, c) {
+				return false
+			}
+			if c.Is(odf.NSText, "span") {
+				spans = append(spans, c)
+			}
+			return c.Kind == xmltree.ElementNode
+		})
+		for _, span := range spans {
+			span.Unwrap()
+		}
+		n.Normalize()
+	case actionSplit:
+		splitParagraph(n, rule.split)
+	case actionDelete:
+		n.Remove()
+	}
+	return nil
+}
+
+// splitParagraph breaks a paragraph in two before each match of re in its
+// text, or at each text:line-break if re is nil. Only text directly in the paragraph or its spans and
+// links is searched, not that of notes or frames.
+func splitParagraph(paragraph *xmltree.Node, re *regexp.Regexp) {
+	var runs []*xmltree.Node // text nodes, in order
+	var breaks []*xmltree.Node
+	var inline func(n *xmltree.Node)
+	inline = func(n *xmltree.Node) {
+		for _, c := range n.Children {
+			switch {
+			case c.Kind == xmltree.TextNode:
+				runs = append(runs, c)
+			case c.Is(odf.NSText, "line-break"):
+				breaks = append(breaks, c)
+			case c.Is(odf.NSText, "span"), c.Is(odf.NSText, "a"):
+				inline(c)
+			}
+		}
+	}
+	inline(paragraph)
+
+	points := breaks
+	if re != nil {
+		points = splitPoints(runs, re)
+	}
+	for i := len(points) - 1; i >= 0; i-- {
+		next := splitBefore(paragraph, points[i])
+		if re == nil {
+			points[i].Remove()
+		}
+		removeEmptySpans(next)
+	}
+	removeEmptySpans(paragraph)
+}
+
+// splitPoints finds where each match of re starts in a run of text nodes,
+// other than at the very start, splitting text nodes so that every match
+// starts a node, and returns those nodes in order
+func splitPoints(runs []*xmltree.Node, re *regexp.Regexp) []*xmltree.Node {
+	var text strings.Builder
+	starts := make([]int, len(runs))
+	for i, run := range runs {
+		starts[i] = text.Len()
+		text.WriteString(run.Data)
+	}
+
+	var offsets []int
+	for _, match := range re.FindAllStringIndex(text.String(), -1) {
+		if match[0] > 0 && (len(offsets) == 0 || offsets[len(offsets)-1] != match[0]) {
+			offsets = append(offsets, match[0])
+		}
+	}
+
+	// Work backwards, so that splitting a node leaves the offsets of the
+	// text before it alone
+	points := make([]*xmltree.Node, len(offsets))
+	for i := len(offsets) - 1; i >= 0; i-- {
+		k := sort.Search(len(starts), func(k int) bool { return starts[k] > offsets[i] }) - 1
+		run, offset := runs[k], offsets[i]-starts[k]
+		if offset == 0 {
+			points[i] = run
+			continue
+		}
+		tail := xmltree.NewText(run.Data[offset:])
+		run.Data = run.Data[:offset]
+		run.InsertAfter(tail)
+		points[i] = tail
+	}
+	return points
+}
+
+// splitBefore moves node and everything after it in a paragraph into a new
+// paragraph just after it, inside copies of the spans node was in, and
+// returns the new paragraph. The copy doesn't keep the paragraph's xml:id,
+// which must be unique.
+func splitBefore(paragraph, node *xmltree.Node) *xmltree.Node {
+	var carried *xmltree.Node // the copy made one level down
+	child := node
+	for {
+		parent := child.Parent
+		shell := &xmltree.Node{Kind: parent.Kind, Name: parent.Name, Prefix: parent.Prefix, Attrs: append([]xmltree.Attr(nil), parent.Attrs...)}
+		start := child.Index()
+		if carried != nil {
+			shell.AppendChild(carried)
+			start++
+		}
+		for _, c := range parent.Children[start:] {
+			shell.AppendChild(c)
+		}
+		parent.Children = parent.Children[:start]
+
+		if parent == paragraph {
+			shell.RemoveAttr(odf.NSXML, "id")
+			paragraph.InsertAfter(shell)
+			return shell
+		}
+		carried, child = shell, parent
+	}
+}
+
+// removeEmptySpans drops the spans in n left with nothing in them
+func removeEmptySpans(n *xmltree.Node) {
+	for _, c := range n.Elements() {
+		removeEmptySpans(c)
+		if c.Is(odf.NSText, "span") && len(c.Children) == 0 {
+			c.Remove()
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Condition
+		wantErr string
+	}{
+		{text: "style=Text body", want: Condition{Key: "style", Value: "Text body"}},
+		{text: "style!=Text body", want: Condition{Key: "style", Value: "Text body", Negate: true}},
+		{text: " style = Quotations ", want: Condition{Key: "style", Value: "Quotations"}},
+		{
+			text: "ancestor=table:table-cell",
+			want: Condition{Key: "ancestor", Value: "table:table-cell", element: xml.Name{Space: odf.NSTable, Local: "table-cell"}},
+		},
+		{
+			text: "previous=text:h",
+			want: Condition{Key: "previous", Value: "text:h", element: xml.Name{Space: odf.NSText, Local: "h"}},
+		},
+		{text: "next=none", want: Condition{Key: "next", Value: "none"}},
+		{text: "previous=Heading 1", want: Condition{Key: "previous", Value: "Heading 1"}},
+		{
+			text: "format=monospace weight=bold",
+			want: Condition{Key: "format", Value: "monospace weight=bold", format: []odf.Predicate{{Property: "monospace", Value: "yes"}, {Property: "weight", Value: "bold"}}},
+		},
+		{
+			text: "direct=style=italic",
+			want: Condition{Key: "direct", Value: "style=italic", format: []odf.Predicate{{Property: "style", Value: "italic"}}},
+		},
+		{text: "style", wantErr: "has no ="},
+		{text: "style=", wantErr: "needs a value"},
+		{text: "colour=red", wantErr: "unknown condition"},
+		{text: "ancestor=list-item", wantErr: "not a prefixed name"},
+		{text: "ancestor=foo:bar", wantErr: "unknown namespace prefix"},
+		{text: "text=(", wantErr: "bad text pattern"},
+		{text: "format=weight=heavy", wantErr: "weight must be one of"},
+		{text: "format=", wantErr: "no predicates"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := parseCondition(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCondition: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCondition = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The text pattern is compiled
+	c, err := parseCondition(`text=^\s*Note:`)
+	if err != nil {
+		t.Fatalf("parseCondition: %v", err)
+	}
+	if c.re == nil || !c.re.MatchString("  Note: x") || c.re.MatchString("A Note:") {
+		t.Errorf("text pattern %v doesn't match as written", c.re)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  []string
+		wantErr string
+	}{
+		{"style", []string{"10", "paragraph", "style", "Body", "style=Text body"}, ""},
+		{"wrap", []string{"10", "paragraph", "wrap", "Literal", "format=monospace"}, ""},
+		{"unwrap with no argument", []string{"10", "span", "unwrap", ""}, ""},
+		{"split at line breaks", []string{"10", "paragraph", "split", ""}, ""},
+		{"split at a pattern", []string{"10", "paragraph", "split", `\d+\.`}, ""},
+		{"delete", []string{"10", "paragraph", "delete", "", "text=^\\s*$"}, ""},
+		{"empty conditions are ignored", []string{"10", "span", "delete", "", "", ""}, ""},
+		{"too few columns", []string{"10", "paragraph", "style"}, "insufficient columns"},
+		{"bad priority", []string{"high", "paragraph", "style", "Body"}, "bad priority"},
+		{"bad target", []string{"10", "table", "style", "Body"}, "target must be paragraph or span"},
+		{"unknown action", []string{"10", "paragraph", "restyle", "Body"}, "unknown action"},
+		{"style needs a name", []string{"10", "paragraph", "style", ""}, "needs a style name"},
+		{"wrap needs a name", []string{"10", "span", "wrap", ""}, "needs a style name"},
+		{"spans can't be split", []string{"10", "span", "split", ""}, "only paragraphs can be split"},
+		{"bad split pattern", []string{"10", "paragraph", "split", "("}, "bad split pattern"},
+		{"direct is for spans", []string{"10", "paragraph", "style", "Body", "direct=weight=bold"}, "only spans have direct formatting"},
+		{"bad condition", []string{"10", "paragraph", "style", "Body", "style"}, "has no ="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseRule(tt.record)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRule: %v", err)
+			}
+			if rule.Target != tt.record[1] || rule.Action != tt.record[2] || rule.Argument != tt.record[3] {
+				t.Errorf("parseRule = %+v", rule)
+			}
+			if (rule.split != nil) != (rule.Action == actionSplit && rule.Argument != "") {
+				t.Errorf("split pattern = %v", rule.split)
+			}
+		})
+	}
+}
+
+func TestLoadRuleSet(t *testing.T) {
+	rules := `#A comment, with a comma
+mode,all
+transforms,headings,code
+transforms,lists
+transforms,headings,bogus
+10,paragraph,style,Body,style=Text body
+20,span,style,Bold,direct=weight=bold
+10,paragraph,style,BodyContinued,"text=^a,b"
+mode,sometimes
+30,paragraph,delete,,text=^$
+5,paragraph,explode,
+`
+	filename := filepath.Join(t.TempDir(), "rules.txt")
+	if err := os.WriteFile(filename, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := LoadRuleSet(filename)
+	if err != nil {
+		t.Fatalf("LoadRuleSet: %v", err)
+	}
+	if !set.AllMatch {
+		t.Errorf("AllMatch = false, want true")
+	}
+	if want := []string{"headings", "code", "lists"}; !reflect.DeepEqual(set.Transforms, want) {
+		t.Errorf("Transforms = %q, want %q", set.Transforms, want)
+	}
+
+	// Highest priority first, keeping file order within a priority
+	var got []string
+	for _, rule := range set.Rules {
+		got = append(got, rule.Action+" "+rule.Argument)
+	}
+	want := []string{"delete ", "style Bold", "style Body", "style BodyContinued"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %q, want %q", got, want)
+	}
+	if line := set.Rules[0].Line; line != 10 {
+		t.Errorf("first rule is from line %d, want 10", line)
+	}
+	if c := set.Rules[3].Conditions[0]; c.Key != "text" || c.Value != "^a,b" {
+		t.Errorf("quoted condition = %+v", c)
+	}
+
+	if _, err := LoadRuleSet(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("LoadRuleSet of a missing file succeeded")
+	}
+}
+
+// testConverter writes a document with the given styles.xml and
+// content.xml sections and opens it for restyling
+func testConverter(t *testing.T, styles, content string) *ParagraphConverter {
+	t.Helper()
+	const namespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+		` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"`
+	pkg := odf.New()
+	pkg.Add(odf.MimetypePath, zip.Store, []byte("application/vnd.oasis.opendocument.text"))
+	pkg.Add(odf.ManifestPath, zip.Deflate, []byte(`<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0">`+
+		`<manifest:file-entry manifest:full-path="/" manifest:media-type="application/vnd.oasis.opendocument.text"/>`+
+		`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>`+
+		`<manifest:file-entry manifest:full-path="styles.xml" manifest:media-type="text/xml"/>`+
+		`</manifest:manifest>`))
+	pkg.Add("styles.xml", zip.Deflate, []byte(`<office:document-styles `+namespaces+`>`+styles+`</office:document-styles>`))
+	pkg.Add("content.xml", zip.Deflate, []byte(`<office:document-content `+namespaces+`>`+content+`</office:document-content>`))
+	filename := filepath.Join(t.TempDir(), "test.odt")
+	if err := pkg.WriteFile(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := odf.OpenDocument(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { doc.Close() })
+	pc, err := NewParagraphConverter(doc, nil)
+	if err != nil {
+		t.Fatalf("NewParagraphConverter: %v", err)
+	}
+	return pc
+}
+
+func TestConditionHolds(t *testing.T) {
+	pc := testConverter(t,
+		`<office:styles>`+
+			`<style:style style:name="Text_20_body" style:display-name="Text body" style:family="paragraph"/>`+
+			`<style:style style:name="Heading_20_1" style:display-name="Heading 1" style:family="paragraph"><style:text-properties fo:font-weight="bold"/></style:style>`+
+			`</office:styles>`,
+		`<office:automatic-styles>`+
+			`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Text_20_body"/>`+
+			`<style:style style:name="T1" style:family="text"><style:text-properties fo:font-weight="bold"/></style:style>`+
+			`</office:automatic-styles>`+
+			`<office:body><office:text>`+
+			`<text:h text:style-name="Heading_20_1">Heading <text:span text:style-name="T1">bold anyway</text:span></text:h>`+
+			`<text:p text:style-name="P1">Body with <text:span text:style-name="T1">strong</text:span> text`+
+			`<text:note text:note-class="footnote"><text:note-body><text:p>Footnote: aside</text:p></text:note-body></text:note></text:p>`+
+			`<tb:table xmlns:tb="urn:oasis:names:tc:opendocument:xmlns:table:1.0"><tb:table-row><tb:table-cell>`+
+			`<text:p text:style-name="Text_20_body">In a cell</text:p>`+
+			`</tb:table-cell></tb:table-row></tb:table>`+
+			`</office:text></office:body>`)
+
+	// find returns the paragraph or span whose own text starts so
+	find := func(start string) *xmltree.Node {
+		for _, n := range pc.targets() {
+			if strings.HasPrefix(inlineText(n), start) {
+				return n
+			}
+		}
+		t.Fatalf("no element starting %q", start)
+		return nil
+	}
+	tests := []struct {
+		element   string
+		condition string
+		want      bool
+	}{
+		{"Body", "style=Text body", true},
+		{"Body", "style=Text_20_body", true},
+		{"Body", "style!=Text body", false},
+		{"Heading", "style=Text body", false},
+		{"In a cell", "ancestor=table:table-cell", true},
+		{"Body", "ancestor=table:table-cell", false},
+		{"In a cell", "ancestor!=table:table", false},
+		{"Body", "previous=text:h", true},
+		{"Body", "previous=Heading 1", true},
+		{"Heading", "previous=none", true},
+		{"Body", "next=table:table", true},
+		{"In a cell", "next=none", true},
+		{"Body", "text=text$", true},
+		{"Body", "text=Footnote", false},
+		{"Footnote", "text=^Footnote", true},
+		{"Heading", "format=weight=bold", true},
+		{"bold anyway", "format=weight=bold", true},
+		{"bold anyway", "direct=weight=bold", false},
+		{"strong", "direct=weight=bold", true},
+		{"strong", "direct=weight=bold style=italic", false},
+		{"strong", "format=weight=normal", false},
+		{"In a cell", "format=weight=normal", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.element+" "+tt.condition, func(t *testing.T) {
+			c, err := parseCondition(tt.condition)
+			if err != nil {
+				t.Fatalf("parseCondition: %v", err)
+			}
+			if got := pc.holds(find(tt.element), c); got != tt.want {
+				t.Errorf("holds = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRulesMergesSpans(t *testing.T) {
+	const automatic = `<office:automatic-styles>` +
+		`<style:style style:name="T1" style:family="text"><style:text-properties fo:font-style="italic"/></style:style>` +
+		`<style:style style:name="T2" style:family="text"><style:text-properties fo:font-style="italic" fo:color="#000000"/></style:style>` +
+		`</office:automatic-styles>`
+
+	tests := []struct {
+		name      string
+		paragraph string
+		rule      []string
+		want      string
+	}{
+		{
+			"styled spans",
+			`<text:span text:style-name="T1">I</text:span><text:span text:style-name="T2">n</text:span> situ`,
+			[]string{"10", "span", "style", "Italic", "direct=style=italic"},
+			`<text:span text:style-name="Italic">In</text:span> situ`,
+		},
+		{
+			"wrapped spans",
+			`<text:span text:style-name="T1">I</text:span><text:span text:style-name="T1">n</text:span> situ`,
+			[]string{"10", "span", "wrap", "Emphasis", "direct=style=italic"},
+			`<text:span text:style-name="T1"><text:span text:style-name="Emphasis">In</text:span></text:span> situ`,
+		},
+		{
+			"spans of different styles stay apart",
+			`<text:span text:style-name="T1">I</text:span><text:span text:style-name="T2">n</text:span> situ`,
+			[]string{"10", "span", "wrap", "Emphasis", "direct=style=italic"},
+			`<text:span text:style-name="T1"><text:span text:style-name="Emphasis">I</text:span></text:span>` +
+				`<text:span text:style-name="T2"><text:span text:style-name="Emphasis">n</text:span></text:span> situ`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := testConverter(t, `<office:styles/>`, automatic+`<office:body><office:text><text:p>`+tt.paragraph+`</text:p></office:text></office:body>`)
+			rule, err := parseRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := pc.ApplyRules(&RuleSet{Rules: []*Rule{rule}, AllMatch: true}, Options{}); err != nil {
+				t.Fatalf("ApplyRules: %v", err)
+			}
+			p := pc.content.Child(odf.NSOffice, "body").Child(odf.NSOffice, "text").Child(odf.NSText, "p")
+			if got := innerXML(p); got != tt.want {
+				t.Errorf("paragraph = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
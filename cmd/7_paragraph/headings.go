@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// HeadingLevel maps a heading level of the source document to a house
+// heading style and the text:outline-level it has in the house style. The
+// source level is N for Heading N and 0 for Title.
+type HeadingLevel struct {
+	Source  int
+	Style   string
+	Outline int
+}
+
+// LoadHeadingLevels reads a CSV file with one heading level per row:
+// source level, house style, outline level. Lines starting with # are
+// comments.
+func LoadHeadingLevels(filename string) ([]HeadingLevel, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	lineCount := 0
+
+	var levels []HeadingLevel
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", lineCount+1, err)
+		}
+
+		lineCount++
+
+		// Skip comment lines
+		if len(record) > 0 && strings.HasPrefix(record[0], "#") {
+			continue
+		}
+
+		// Ensure we have all three columns
+		if len(record) < 3 {
+			log.Printf("Warning: skipping line %d - insufficient columns", lineCount)
+			continue
+		}
+
+		source, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil || source < 0 {
+			log.Printf("Warning: skipping line %d - bad source level '%s'", lineCount, record[0])
+			continue
+		}
+		outline, err := strconv.Atoi(strings.TrimSpace(record[2]))
+		if err != nil || outline < 1 || outline > 10 {
+			log.Printf("Warning: skipping line %d - outline level must be 1 to 10, got '%s'", lineCount, record[2])
+			continue
+		}
+		style := strings.TrimSpace(record[1])
+		if style == "" {
+			log.Printf("Warning: skipping line %d - empty style name", lineCount)
+			continue
+		}
+
+		levels = append(levels, HeadingLevel{Source: source, Style: style, Outline: outline})
+	}
+
+	fmt.Printf("Loaded %d heading levels from %s\n", len(levels), filename)
+	return levels, nil
+}
+
+// outlineLevels returns the number of levels the document's outline
+// numbering (text:outline-style) defines, or 10 if it has none
+func (pc *ParagraphConverter) outlineLevels() int {
+	levels := 0
+	if outline := pc.commonStyles.Child(odf.NSText, "outline-style"); outline != nil {
+		for _, n := range outline.Elements() {
+			if level, err := strconv.Atoi(n.AttrValue(odf.NSText, "level")); err == nil && level > levels {
+				levels = level
+			}
+		}
+	}
+	if levels == 0 {
+		return 10
+	}
+	return levels
+}
+
+// headingLevel works out whether a paragraph is a heading, and its source
+// level, from the first style in its ancestry that says: a house heading
+// style, a style the outline numbering assigns a level to through
+// style:default-outline-level, Heading N, or Title. A text:h whose styles
+// say nothing has the level it is written with.
+func (pc *ParagraphConverter) headingLevel(paragraph *xmltree.Node, levels []HeadingLevel) (int, bool) {
+	outlineLevels := pc.outlineLevels()
+	for _, def := range pc.chain(paragraph) {
+		for _, level := range levels {
+			if hasStyle(def, level.Style) {
+				return level.Source, true
+			}
+		}
+		if level, err := strconv.Atoi(def.Node.AttrValue(odf.NSStyle, "default-outline-level")); err == nil && level >= 1 && level <= outlineLevels {
+			return level, true
+		}
+		name := odf.DecodeStyleName(def.Key.Name)
+		if number, ok := strings.CutPrefix(name, "Heading "); ok {
+			if level, err := strconv.Atoi(number); err == nil && level >= 1 {
+				return level, true
+			}
+		}
+		if name == "Title" {
+			return 0, true
+		}
+	}
+	if paragraph.Is(odf.NSText, "h") {
+		if level, err := strconv.Atoi(paragraph.AttrValue(odf.NSText, "outline-level")); err == nil {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// ConvertHeadings gives every heading its house heading style, making it a
+// text:h with the house outline level, and reports headings that skip a
+// level, such as a HeadC straight after a HeadA
+func (pc *ParagraphConverter) ConvertHeadings(levels []HeadingLevel) error {
+	bySource := make(map[int]HeadingLevel)
+	for _, level := range levels {
+		bySource[level.Source] = level
+	}
+
+	// Find the headings before changing any styles
+	type heading struct {
+		paragraph *xmltree.Node
+		level     HeadingLevel
+		basedOn   string
+	}
+	var headings []heading
+	for _, paragraph := range pc.bodyParagraphs() {
+		source, ok := pc.headingLevel(paragraph, levels)
+		if !ok {
+			continue
+		}
+		level, ok := bySource[source]
+		if !ok {
+			pc.report.Warn("no house style for heading level %d, left alone: %s", source, excerpt(paragraph))
+			continue
+		}
+		headings = append(headings, heading{paragraph: paragraph, level: level, basedOn: pc.namedStyle(paragraph)})
+	}
+
+	var previous *HeadingLevel
+	for i, h := range headings {
+		name, err := pc.ensureStyle(h.level.Style, h.basedOn, func(style *xmltree.Node) {
+			style.SetAttr(odf.NSStyle, "default-outline-level", strconv.Itoa(h.level.Outline))
+		})
+		if err != nil {
+			return err
+		}
+
+		h.paragraph.Name.Local = "h"
+		h.paragraph.SetAttr(odf.NSText, "outline-level", strconv.Itoa(h.level.Outline))
+		pc.setStyle(h.paragraph, name)
+
+		if previous != nil && h.level.Outline > previous.Outline+1 {
+			pc.report.Warn("%s followed directly by %s: %s", previous.Style, h.level.Style, excerpt(h.paragraph))
+		}
+		previous = &headings[i].level
+	}
+	fmt.Printf("Converted %d headings\n", len(headings))
+	return pc.refresh()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// testHeadingLevels is the mapping headings.txt ships with
+var testHeadingLevels = []HeadingLevel{
+	{Source: 0, Style: "ChapterTitle", Outline: 1},
+	{Source: 1, Style: "HeadA", Outline: 2},
+	{Source: 2, Style: "HeadB", Outline: 3},
+	{Source: 3, Style: "HeadC", Outline: 4},
+}
+
+// testHeadingStyles are the common styles the heading tests use, with
+// outline numbering of four levels
+const testHeadingStyles = `<office:styles>` +
+	`<style:style style:name="Heading" style:family="paragraph"/>` +
+	`<style:style style:name="Heading_20_1" style:display-name="Heading 1" style:family="paragraph" style:parent-style-name="Heading"/>` +
+	`<style:style style:name="Heading_20_2" style:display-name="Heading 2" style:family="paragraph" style:parent-style-name="Heading"/>` +
+	`<style:style style:name="Heading_20_3" style:display-name="Heading 3" style:family="paragraph" style:parent-style-name="Heading"/>` +
+	`<style:style style:name="Heading_20_4" style:display-name="Heading 4" style:family="paragraph" style:parent-style-name="Heading"/>` +
+	`<style:style style:name="Title" style:family="paragraph" style:parent-style-name="Heading"/>` +
+	`<style:style style:name="HeadB" style:family="paragraph"/>` +
+	`<style:style style:name="Chapter" style:family="paragraph" style:default-outline-level="1"/>` +
+	`<style:style style:name="Deep" style:family="paragraph" style:default-outline-level="5"/>` +
+	`<style:style style:name="Sidebar_20_Heading" style:display-name="Sidebar Heading" style:family="paragraph" style:parent-style-name="Heading_20_3"/>` +
+	`<style:style style:name="Text_20_body" style:display-name="Text body" style:family="paragraph"/>` +
+	`<text:outline-style style:name="Outline">` +
+	`<text:outline-level-style text:level="1"/><text:outline-level-style text:level="2"/>` +
+	`<text:outline-level-style text:level="3"/><text:outline-level-style text:level="4"/>` +
+	`</text:outline-style>` +
+	`</office:styles>`
+
+func TestHeadingLevel(t *testing.T) {
+	tests := []struct {
+		paragraph string
+		level     int
+		ok        bool
+	}{
+		{`<text:p text:style-name="Heading_20_1">Heading 1</text:p>`, 1, true},
+		{`<text:p text:style-name="P1">automatic style based on Heading 2</text:p>`, 2, true},
+		{`<text:p text:style-name="Title">Title</text:p>`, 0, true},
+		{`<text:p text:style-name="HeadB">house style</text:p>`, 2, true},
+		{`<text:p text:style-name="Chapter">default outline level</text:p>`, 1, true},
+		{`<text:p text:style-name="Deep">outline level beyond the numbering</text:p>`, 0, false},
+		{`<text:p text:style-name="Sidebar_20_Heading">inherits from Heading 3</text:p>`, 3, true},
+		{`<text:h text:outline-level="3">text:h with no style</text:h>`, 3, true},
+		{`<text:h text:style-name="HeadB" text:outline-level="3">style wins over the element</text:h>`, 2, true},
+		{`<text:p text:style-name="Heading">Heading itself</text:p>`, 0, false},
+		{`<text:p text:style-name="Text_20_body">body</text:p>`, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.paragraph, func(t *testing.T) {
+			pc := testConverter(t, testHeadingStyles,
+				`<office:automatic-styles><style:style style:name="P1" style:family="paragraph" style:parent-style-name="Heading_20_2"><style:paragraph-properties fo:margin-top="1in"/></style:style></office:automatic-styles>`+
+					`<office:body><office:text>`+tt.paragraph+`</office:text></office:body>`)
+			level, ok := pc.headingLevel(pc.bodyParagraphs()[0], testHeadingLevels)
+			if level != tt.level || ok != tt.ok {
+				t.Errorf("headingLevel = %d, %v, want %d, %v", level, ok, tt.level, tt.ok)
+			}
+		})
+	}
+}
+
+func TestConvertHeadings(t *testing.T) {
+	pc := testConverter(t, testHeadingStyles,
+		`<office:automatic-styles/><office:body><office:text>`+
+			`<text:p text:style-name="Title">Title</text:p>`+
+			`<text:p text:style-name="Heading_20_1">One</text:p>`+
+			`<text:p text:style-name="Text_20_body">body</text:p>`+
+			`<text:h text:style-name="Heading_20_3" text:outline-level="3">Skipped a level</text:h>`+
+			`<text:p text:style-name="Heading_20_4">No house style</text:p>`+
+			`<text:p text:style-name="Heading_20_2">Two</text:p>`+
+			`</office:text></office:body>`)
+
+	if err := pc.ConvertHeadings(testHeadingLevels); err != nil {
+		t.Fatalf("ConvertHeadings: %v", err)
+	}
+
+	type result struct{ element, style, outline string }
+	var got []result
+	for _, paragraph := range pc.bodyParagraphs() {
+		got = append(got, result{
+			paragraph.QName(),
+			paragraph.AttrValue(odf.NSText, "style-name"),
+			paragraph.AttrValue(odf.NSText, "outline-level"),
+		})
+	}
+	want := []result{
+		{"text:h", "ChapterTitle", "1"},
+		{"text:h", "HeadA", "2"},
+		{"text:p", "Text_20_body", ""},
+		{"text:h", "HeadC", "4"},
+		{"text:p", "Heading_20_4", ""},
+		{"text:h", "HeadB", "3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paragraphs = %v\nwant %v", got, want)
+	}
+
+	wantWarnings := []string{
+		`no house style for heading level 4, left alone: "No house style"`,
+		`HeadA followed directly by HeadC: "Skipped a level"`,
+	}
+	if !reflect.DeepEqual(pc.report.Warnings, wantWarnings) {
+		t.Errorf("warnings = %q\nwant %q", pc.report.Warnings, wantWarnings)
+	}
+
+	// The created house styles carry their outline level; HeadB was there
+	// already, and is left as it was
+	for _, level := range testHeadingLevels {
+		style := findStyle(pc.commonStyles, "paragraph", level.Style)
+		if style == nil {
+			t.Errorf("%s wasn't created", level.Style)
+			continue
+		}
+		if level.Style != "HeadB" && style.AttrValue(odf.NSStyle, "default-outline-level") == "" {
+			t.Errorf("%s has no default outline level", level.Style)
+		}
+	}
+}
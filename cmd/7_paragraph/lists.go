@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// A list item's paragraphs get their style from the kind of numbering the
+// item has and how deeply it is nested. The numbering comes from the list
+// style in effect at the item's level: the item's text:style-override, the
+// style of the innermost text:list that names one, or the list style of
+// the paragraph's own style.
+
+// listKind is the kind of label a list level has
+type listKind int
+
+const (
+	listBullet listKind = iota
+	listNumber
+	listLetter
+	listPlain
+)
+
+// listStyles gives the top-level and nested house style of each kind
+var listStyles = map[listKind][2]string{
+	listBullet: {"ListBullet", "ListBulletSub"},
+	listNumber: {"ListNumber", "ListNumberSub"},
+	listLetter: {"ListLetter", "ListLetterSub"},
+	listPlain:  {"ListPlain", "ListPlain"},
+}
+
+// House styles for unnumbered paragraphs in lists
+const (
+	listBodyStyle      = "ListBody"
+	listContinuedStyle = "ListContinued"
+)
+
+// listDefinitions returns the style definitions of content.xml and then of
+// styles.xml, the order a list style is looked for in
+func (pc *ParagraphConverter) listDefinitions() []map[odf.StyleKey]*xmltree.Node {
+	var definitions []map[odf.StyleKey]*xmltree.Node
+	for _, part := range []string{"content.xml", "styles.xml"} {
+		doc, err := pc.doc.Part(part)
+		if err != nil || doc == nil {
+			continue
+		}
+		definitions = append(definitions, odf.Definitions(doc.Root))
+	}
+	return definitions
+}
+
+// listLevel finds the level style a list style has for a nesting depth,
+// looking for the list style among the automatic styles of content.xml and
+// then the common styles
+func listLevel(definitions []map[odf.StyleKey]*xmltree.Node, name string, depth int) *xmltree.Node {
+	for _, defined := range definitions {
+		style := defined[odf.StyleKey{Family: "list", Name: name}]
+		if style == nil {
+			continue
+		}
+		for _, level := range style.Elements() {
+			if level.AttrValue(odf.NSText, "level") == strconv.Itoa(depth) {
+				return level
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// kindOf classifies a list level style by its label
+func kindOf(level *xmltree.Node) listKind {
+	switch {
+	case level.Is(odf.NSText, "list-level-style-bullet"), level.Is(odf.NSText, "list-level-style-image"):
+		return listBullet
+	}
+	switch level.AttrValue(odf.NSStyle, "num-format") {
+	case "":
+		return listPlain
+	case "a", "A":
+		return listLetter
+	}
+	return listNumber
+}
+
+// paragraphListStyle returns the style:list-style-name of a paragraph's
+// style or the styles it inherits from
+func (pc *ParagraphConverter) paragraphListStyle(paragraph *xmltree.Node) string {
+	for _, def := range pc.chain(paragraph) {
+		if name, ok := def.Node.Attr(odf.NSStyle, "list-style-name"); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// listContext is what a list passes on to the lists nested in it
+type listContext struct {
+	style      string // list style in effect
+	depth      int    // 1 for a top-level list
+	continuing bool   // the list carries on from one interrupted before it
+}
+
+// ConvertLists restyles the paragraphs of every list in the body
+func (pc *ParagraphConverter) ConvertLists() error {
+	body := pc.content.Child(odf.NSOffice, "body")
+	if body == nil {
+		return nil
+	}
+
+	var lists []*xmltree.Node
+	body.Walk(func(n *xmltree.Node) bool {
+		if n.Is(odf.NSText, "list") {
+			lists = append(lists, n)
+			return false
+		}
+		return n.Kind == xmltree.ElementNode && !n.Is(odf.NSText, "index-body")
+	})
+
+	// Work out every paragraph's style before changing any, since the
+	// paragraph styles can supply the list style
+	definitions := pc.listDefinitions()
+	restyle := make(map[*xmltree.Node]string)
+	var order []*xmltree.Node
+	var visit func(list *xmltree.Node, context listContext)
+	visit = func(list *xmltree.Node, context listContext) {
+		if name, ok := list.Attr(odf.NSText, "style-name"); ok {
+			context.style = name
+		}
+		if list.AttrValue(odf.NSText, "continue-numbering") == "true" || list.AttrValue(odf.NSText, "continue-list") != "" {
+			context.continuing = true
+		}
+
+		for _, item := range list.Elements() {
+			header := item.Is(odf.NSText, "list-header")
+			if !header && !item.Is(odf.NSText, "list-item") {
+				continue
+			}
+			itemContext := context
+			if name, ok := item.Attr(odf.NSText, "style-override"); ok {
+				itemContext.style = name
+			}
+
+			first := true
+			for _, n := range item.Elements() {
+				if n.Is(odf.NSText, "list") {
+					visit(n, listContext{style: itemContext.style, depth: context.depth + 1, continuing: context.continuing})
+					first = false
+					continue
+				}
+				if !n.Is(odf.NSText, "p") || pc.isCode(n) {
+					first = false
+					continue
+				}
+
+				var style string
+				switch {
+				case (header || !first) && context.continuing:
+					style = listContinuedStyle
+				case header || !first:
+					style = listBodyStyle
+				default:
+					style = pc.itemStyle(n, itemContext, definitions)
+				}
+				if style != "" {
+					restyle[n] = style
+					order = append(order, n)
+				}
+				first = false
+			}
+		}
+	}
+	for _, list := range lists {
+		visit(list, listContext{depth: 1})
+	}
+
+	for _, paragraph := range order {
+		name, err := pc.ensureStyle(restyle[paragraph], pc.namedStyle(paragraph), nil)
+		if err != nil {
+			return err
+		}
+		pc.setStyle(paragraph, name)
+	}
+	fmt.Printf("Converted %d list paragraphs in %d lists\n", len(order), len(lists))
+	return pc.refresh()
+}
+
+// itemStyle returns the house style for the numbered paragraph of a list
+// item, or "" if the list style in effect can't be found
+func (pc *ParagraphConverter) itemStyle(paragraph *xmltree.Node, context listContext, definitions []map[odf.StyleKey]*xmltree.Node) string {
+	name := context.style
+	if name == "" {
+		name = pc.paragraphListStyle(paragraph)
+	}
+	depth := context.depth
+	if depth > 10 {
+		depth = 10
+	}
+	level := listLevel(definitions, name, depth)
+	if level == nil {
+		pc.report.Warn("no level %d in list style '%s', left alone: %s", depth, name, excerpt(paragraph))
+		return ""
+	}
+
+	styles := listStyles[kindOf(level)]
+	if context.depth > 1 {
+		return styles[1]
+	}
+	return styles[0]
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestConvertLists(t *testing.T) {
+	const automatic = `<office:automatic-styles>` +
+		`<text:list-style style:name="L1">` +
+		`<text:list-level-style-bullet text:level="1" text:bullet-char="•"/>` +
+		`<text:list-level-style-number text:level="2" style:num-format="1"/>` +
+		`<text:list-level-style-number text:level="3" style:num-format="a"/>` +
+		`</text:list-style>` +
+		`<text:list-style style:name="L2"><text:list-level-style-number text:level="1" style:num-format=""/></text:list-style>` +
+		`<text:list-style style:name="L3"><text:list-level-style-number text:level="1" style:num-format="A"/></text:list-style>` +
+		`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Numbered" style:list-style-name="L4"/>` +
+		`</office:automatic-styles>`
+	const styles = `<office:styles>` +
+		`<text:list-style style:name="L4"><text:list-level-style-number text:level="1" style:num-format="i"/></text:list-style>` +
+		`<style:style style:name="Numbered" style:family="paragraph"/>` +
+		`</office:styles>`
+	const body = `<office:body><office:text>` +
+		`<text:list text:style-name="L1">` +
+		`<text:list-item><text:p>bullet</text:p></text:list-item>` +
+		`<text:list-item><text:p>bullet with more</text:p><text:p>more of the item</text:p>` +
+		`<text:list><text:list-item><text:p>number sub</text:p>` +
+		`<text:list><text:list-item><text:p>letter sub</text:p></text:list-item></text:list>` +
+		`</text:list-item></text:list>` +
+		`<text:p>after the nested list</text:p>` +
+		`</text:list-item>` +
+		`<text:list-header><text:p>header</text:p></text:list-header>` +
+		`<text:list-item text:style-override="L2"><text:p>plain override</text:p></text:list-item>` +
+		`</text:list>` +
+		`<text:p>interruption</text:p>` +
+		`<text:list text:style-name="L1" text:continue-numbering="true">` +
+		`<text:list-item><text:p>resumed</text:p><text:p>resumed more</text:p>` +
+		`<text:list><text:list-item><text:p>resumed sub</text:p><text:p>resumed sub more</text:p></text:list-item></text:list>` +
+		`</text:list-item>` +
+		`<text:list-header><text:p>resumed header</text:p></text:list-header>` +
+		`</text:list>` +
+		`<text:list text:style-name="L3"><text:list-item><text:p>letter</text:p></text:list-item></text:list>` +
+		`<text:list><text:list-item><text:p text:style-name="P1">paragraph's list style</text:p></text:list-item></text:list>` +
+		`<text:list text:style-name="Nowhere"><text:list-item><text:p>unknown list style</text:p></text:list-item></text:list>` +
+		`</office:text></office:body>`
+
+	pc := testConverter(t, styles, automatic+body)
+	if err := pc.ConvertLists(); err != nil {
+		t.Fatalf("ConvertLists: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, paragraph := range pc.bodyParagraphs() {
+		style := paragraph.AttrValue(odf.NSText, "style-name")
+		if def := pc.styleOf(paragraph); def != nil && def.Automatic {
+			style = def.ParentName()
+		}
+		got[strings.TrimSpace(paragraph.Text())] = style
+	}
+	want := map[string]string{
+		"bullet":                 "ListBullet",
+		"bullet with more":       "ListBullet",
+		"more of the item":       "ListBody",
+		"number sub":             "ListNumberSub",
+		"letter sub":             "ListLetterSub",
+		"after the nested list":  "ListBody",
+		"header":                 "ListBody",
+		"plain override":         "ListPlain",
+		"interruption":           "",
+		"resumed":                "ListBullet",
+		"resumed more":           "ListContinued",
+		"resumed sub":            "ListNumberSub",
+		"resumed sub more":       "ListContinued",
+		"resumed header":         "ListContinued",
+		"letter":                 "ListLetter",
+		"paragraph's list style": "ListNumber",
+		"unknown list style":     "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		for text, style := range want {
+			if got[text] != style {
+				t.Errorf("%q has style %q, want %q", text, got[text], style)
+			}
+		}
+	}
+
+	wantWarnings := []string{`no level 1 in list style 'Nowhere', left alone: "unknown list style"`}
+	if !reflect.DeepEqual(pc.report.Warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", pc.report.Warnings, wantWarnings)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		element   string
+		numFormat string // "-" leaves style:num-format out
+		want      listKind
+	}{
+		{"list-level-style-bullet", "-", listBullet},
+		{"list-level-style-image", "-", listBullet},
+		{"list-level-style-number", "1", listNumber},
+		{"list-level-style-number", "i", listNumber},
+		{"list-level-style-number", "a", listLetter},
+		{"list-level-style-number", "A", listLetter},
+		{"list-level-style-number", "", listPlain},
+		{"list-level-style-number", "-", listPlain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.element+" "+tt.numFormat, func(t *testing.T) {
+			level := xmltree.NewElement(xml.Name{Space: odf.NSText, Local: tt.element})
+			if tt.numFormat != "-" {
+				level.SetAttr(odf.NSStyle, "num-format", tt.numFormat)
+			}
+			if got := kindOf(level); got != tt.want {
+				t.Errorf("kindOf = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+)
+
+// Options holds the configuration files and template the transforms use
+type Options struct {
+	Template string // house template to copy missing styles from
+	Headings string // heading levels file
+	Context  string // context rules file
+	Rules    string // conversion rules file
+	Trace    bool   // show which rule fired for each paragraph
+	Code     CodeOptions
+}
+
+// parseOptions takes the --template=<file>, --headings=<file>,
+// --context=<file>, --rules=<file>, --trace, --code-width=<n>,
+// --tab-width=<n> and --callouts=<regexp> options out of args
+func parseOptions(args []string, options *Options) ([]string, error) {
+	var rest []string
+	options.Headings = "headings.txt"
+	options.Context = "context.txt"
+	options.Rules = "rules.txt"
+	options.Code = CodeOptions{Width: 72, TabWidth: 8, Callouts: regexp.MustCompile(defaultCallouts)}
+	for _, arg := range args {
+		var err error
+		switch {
+		case strings.HasPrefix(arg, "--context="):
+			options.Context = strings.TrimPrefix(arg, "--context=")
+		case strings.HasPrefix(arg, "--rules="):
+			options.Rules = strings.TrimPrefix(arg, "--rules=")
+		case arg == "--trace":
+			options.Trace = true
+		case strings.HasPrefix(arg, "--code-width="):
+			options.Code.Width, err = strconv.Atoi(strings.TrimPrefix(arg, "--code-width="))
+		case strings.HasPrefix(arg, "--tab-width="):
+			options.Code.TabWidth, err = strconv.Atoi(strings.TrimPrefix(arg, "--tab-width="))
+			if err == nil && options.Code.TabWidth < 1 {
+				err = fmt.Errorf("tab width must be at least 1")
+			}
+		case strings.HasPrefix(arg, "--callouts="):
+			options.Code.Callouts, err = regexp.Compile(strings.TrimPrefix(arg, "--callouts="))
+		case strings.HasPrefix(arg, "--template="):
+			options.Template = strings.TrimPrefix(arg, "--template=")
+		case strings.HasPrefix(arg, "--headings="):
+			options.Headings = strings.TrimPrefix(arg, "--headings=")
+		default:
+			rest = append(rest, arg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad option %s: %w", arg, err)
+		}
+	}
+	return rest, nil
+}
+
+// restyledFilename creates the output filename by adding _restyled before .odt
+func restyledFilename(inputPath string) string {
+	ext := filepath.Ext(inputPath)
+	return strings.TrimSuffix(inputPath, ext) + "_restyled" + ext
+}
+
+// convert opens a document, runs a transform on it and saves the result
+func convert(inputPath, outputPath string, options Options, transform func(*ParagraphConverter) error) error {
+	doc, err := odf.OpenDocument(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", inputPath, err)
+	}
+	defer doc.Close()
+
+	var template *odf.Document
+	if options.Template != "" {
+		if template, err = odf.OpenDocument(options.Template); err != nil {
+			return fmt.Errorf("failed to open template %s: %w", options.Template, err)
+		}
+		defer template.Close()
+	}
+
+	pc, err := NewParagraphConverter(doc, template)
+	if err != nil {
+		return err
+	}
+	if err := transform(pc); err != nil {
+		return err
+	}
+	pc.report.Print()
+
+	if err := doc.Save(outputPath); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	fmt.Printf("Saved %s\n", outputPath)
+	return nil
+}
+
+func usage() {
+	fmt.Printf("Usage: %s <command> <doc.odt> [out.odt]\n", os.Args[0])
+	fmt.Println("  headings: make headings text:h elements with the house heading styles and outline levels")
+	fmt.Println("    --headings=<file>: heading levels to map (default: headings.txt)")
+	fmt.Println("  code: give listings of monospace or Preformatted Text paragraphs Code, CodeWide or CodeAnnotated")
+	fmt.Println("    --code-width=<n>: lines longer than this make a listing CodeWide (default: 72)")
+	fmt.Println("    --tab-width=<n>: columns between tab stops when measuring lines (default: 8)")
+	fmt.Println("    --callouts=<regexp>: lines matching this make a listing CodeAnnotated")
+	fmt.Println("    (default: a circled number or <n> at the end of the line)")
+	fmt.Println("  lists: style list paragraphs by numbering and depth: ListBullet, ListNumber, ListLetter and their")
+	fmt.Println("    Sub variants, ListPlain, ListBody for the unnumbered paragraphs of an item and ListContinued")
+	fmt.Println("    for unnumbered paragraphs in a list that resumes after an interruption")
+	fmt.Println("  context: style body paragraphs Body, BodyContinued, ListContinued or NoteContinued by the blocks")
+	fmt.Println("    before them")
+	fmt.Println("    --context=<file>: rules to apply (default: context.txt)")
+	fmt.Println("  rules: run the transforms a rules file names, then restyle, wrap, unwrap, split or delete")
+	fmt.Println("    paragraphs and spans as its rules say")
+	fmt.Println("    --rules=<file>: rules to apply (default: rules.txt)")
+	fmt.Println("    --trace: show which rules fired for each paragraph and span")
+	fmt.Println("  all: headings, code, lists and context, in that order")
+	fmt.Println("  The default output is doc_restyled.odt. Every command takes")
+	fmt.Println("    --template=<house.ott>: copy missing house styles from a template rather than creating them")
+	os.Exit(1)
+}
+
+func main() {
+	var options Options
+	args, err := parseOptions(os.Args, &options)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(args) != 3 && len(args) != 4 {
+		usage()
+	}
+	inputPath, outputPath := args[2], restyledFilename(args[2])
+	if len(args) == 4 {
+		outputPath = args[3]
+	}
+
+	var transform func(*ParagraphConverter) error
+	switch args[1] {
+	case "headings", "code", "lists", "context":
+		transform = func(pc *ParagraphConverter) error {
+			return pc.RunTransform(args[1], options)
+		}
+	case "rules":
+		transform = func(pc *ParagraphConverter) error {
+			rules, err := LoadRuleSet(options.Rules)
+			if err != nil {
+				return err
+			}
+			return pc.ApplyRules(rules, options)
+		}
+	case "all":
+		transform = func(pc *ParagraphConverter) error {
+			return pc.ApplyRules(&RuleSet{Transforms: transforms}, options)
+		}
+	default:
+		usage()
+	}
+
+	if err := convert(inputPath, outputPath, options, transform); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
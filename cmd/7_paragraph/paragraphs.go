@@ -0,0 +1,292 @@
+package main
+
+/*
+Paragraph Converter: gives paragraphs the No Starch paragraph styles by
+what they are rather than by what they are called. Renaming Heading_20_1
+to HeadA (cmd/5_rewrite) can't turn a text:p into a text:h, or tell a code
+listing typed in Standard from the text around it; the transforms here look
+at a paragraph's style ancestry, its content and its neighbours instead.
+
+Paragraphs nearly always carry an automatic style (P12) based on the named
+style. Restyling such a paragraph gives it a copy of the automatic style
+based on the house style, so that its direct formatting, page breaks and
+list style survive, and other paragraphs sharing the original are left
+alone. Run cmd/5_rewrite gc-styles afterwards to drop the originals.
+*/
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"LibreOfficeReformatter/odf"
+	"LibreOfficeReformatter/xmltree"
+)
+
+// Report records what the transforms changed and what they couldn't
+type Report struct {
+	Counts   map[string]int // paragraphs given each style
+	Created  []string       // house styles the document lacked
+	Imported []string       // house styles copied from the template
+	Warnings []string
+}
+
+// Warn records something an editor should look at
+func (r *Report) Warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Print displays the report
+func (r *Report) Print() {
+	fmt.Println("\n=== Paragraph Report ===")
+	var names []string
+	total := 0
+	for name, count := range r.Counts {
+		names = append(names, name)
+		total += count
+	}
+	sort.Strings(names)
+	fmt.Printf("Total paragraphs restyled: %d\n", total)
+	for _, name := range names {
+		fmt.Printf("  %s: %d paragraphs\n", name, r.Counts[name])
+	}
+	if len(r.Imported) > 0 {
+		fmt.Printf("Copied from the template: %s\n", strings.Join(r.Imported, ", "))
+	}
+	if len(r.Created) > 0 {
+		fmt.Printf("Created: %s\n", strings.Join(r.Created, ", "))
+	}
+	if len(r.Warnings) > 0 {
+		fmt.Printf("Warnings: %d\n", len(r.Warnings))
+		for _, warning := range r.Warnings {
+			fmt.Printf("  %s\n", warning)
+		}
+	}
+}
+
+// ParagraphConverter holds a document being restyled
+type ParagraphConverter struct {
+	doc             *odf.Document
+	template        *odf.Document // house template to copy missing styles from, if any
+	content         *xmltree.Node // root of content.xml
+	commonStyles    *xmltree.Node // office:styles of styles.xml
+	automaticStyles *xmltree.Node // office:automatic-styles of content.xml
+	styles          *odf.Styles
+	restyled        map[[2]string]string // automatic style and new parent to the copy based on it
+	nextAutomatic   int                  // number of the next automatic paragraph style, P<n>
+	report          *Report
+}
+
+// NewParagraphConverter prepares a document for restyling. Editing session
+// ids are stripped first, as they are before every other transform.
+func NewParagraphConverter(doc *odf.Document, template *odf.Document) (*ParagraphConverter, error) {
+	stripped, err := doc.StripRsids("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to strip rsids: %w", err)
+	}
+	fmt.Printf("Stripped %d rsids, removing %d emptied automatic styles and unwrapping %d spans\n",
+		stripped.Properties, len(stripped.Styles), stripped.Spans)
+
+	content, err := doc.Part("content.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content.xml: %w", err)
+	}
+	if content == nil {
+		return nil, fmt.Errorf("the document has no content.xml")
+	}
+	stylesPart, err := doc.Part("styles.xml")
+	if err != nil || stylesPart == nil || stylesPart.Root.Child(odf.NSOffice, "styles") == nil {
+		return nil, fmt.Errorf("styles.xml has no office:styles to add paragraph styles to")
+	}
+	automatic := content.Root.Child(odf.NSOffice, "automatic-styles")
+	if automatic == nil {
+		return nil, fmt.Errorf("content.xml has no office:automatic-styles")
+	}
+
+	pc := &ParagraphConverter{
+		doc:             doc,
+		template:        template,
+		content:         content.Root,
+		commonStyles:    stylesPart.Root.Child(odf.NSOffice, "styles"),
+		automaticStyles: automatic,
+		restyled:        make(map[[2]string]string),
+		nextAutomatic:   1,
+		report:          &Report{Counts: make(map[string]int)},
+	}
+	for _, n := range automatic.Elements() {
+		name := n.AttrValue(odf.NSStyle, "name")
+		if number, err := strconv.Atoi(strings.TrimPrefix(name, "P")); err == nil && strings.HasPrefix(name, "P") && number >= pc.nextAutomatic {
+			pc.nextAutomatic = number + 1
+		}
+	}
+	return pc, pc.refresh()
+}
+
+// refresh re-indexes the styles after a transform has added some
+func (pc *ParagraphConverter) refresh() error {
+	styles, err := pc.doc.Styles("")
+	if err != nil {
+		return err
+	}
+	pc.styles = styles
+	return nil
+}
+
+// bodyParagraphs returns the text:p and text:h elements of the document
+// body in order, leaving out the generated text of tables of contents and
+// indexes
+func (pc *ParagraphConverter) bodyParagraphs() []*xmltree.Node {
+	var found []*xmltree.Node
+	body := pc.content.Child(odf.NSOffice, "body")
+	if body == nil {
+		return nil
+	}
+	body.Walk(func(n *xmltree.Node) bool {
+		if n.Is(odf.NSText, "index-body") {
+			return false
+		}
+		if isParagraph(n) {
+			found = append(found, n)
+		}
+		return n.Kind == xmltree.ElementNode
+	})
+	return found
+}
+
+func isParagraph(n *xmltree.Node) bool {
+	return n.Is(odf.NSText, "p") || n.Is(odf.NSText, "h")
+}
+
+// styleOf returns the style a paragraph refers to, automatic or common
+func (pc *ParagraphConverter) styleOf(paragraph *xmltree.Node) *odf.StyleDef {
+	name := paragraph.AttrValue(odf.NSText, "style-name")
+	return pc.styles.Lookup("content.xml", odf.StyleKey{Family: "paragraph", Name: name})
+}
+
+// chain returns a paragraph's style followed by the styles it inherits from
+func (pc *ParagraphConverter) chain(paragraph *xmltree.Node) []*odf.StyleDef {
+	var defs []*odf.StyleDef
+	seen := make(map[*odf.StyleDef]bool)
+	for def := pc.styleOf(paragraph); def != nil && !seen[def]; def = pc.styles.Parent(def) {
+		seen[def] = true
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// namedStyle returns the name of the common style a paragraph has, through
+// its automatic style if it has one, or ""
+func (pc *ParagraphConverter) namedStyle(paragraph *xmltree.Node) string {
+	if def := pc.styles.Named(pc.styleOf(paragraph)); def != nil {
+		return def.Key.Name
+	}
+	return ""
+}
+
+// hasStyle reports whether a style definition is the given house style,
+// by internal or display name
+func hasStyle(def *odf.StyleDef, name string) bool {
+	return def.Key.Name == name || def.Key.Name == odf.EncodeStyleName(name) ||
+		def.Node.AttrValue(odf.NSStyle, "display-name") == name
+}
+
+// setStyle gives a paragraph a house style, which must exist. A paragraph
+// with an automatic style gets a copy of it based on the house style. It
+// reports whether the paragraph's style changed, and only then counts it.
+func (pc *ParagraphConverter) setStyle(paragraph *xmltree.Node, name string) bool {
+	def := pc.styleOf(paragraph)
+	switch {
+	case def == nil || !def.Automatic:
+		if paragraph.AttrValue(odf.NSText, "style-name") == name {
+			return false
+		}
+		paragraph.SetAttr(odf.NSText, "style-name", name)
+	case def.ParentName() == name:
+		return false
+	default:
+		pair := [2]string{def.Key.Name, name}
+		copied, ok := pc.restyled[pair]
+		if !ok {
+			copied = fmt.Sprintf("P%d", pc.nextAutomatic)
+			pc.nextAutomatic++
+			clone := def.Node.Clone()
+			clone.SetAttr(odf.NSStyle, "name", copied)
+			clone.SetAttr(odf.NSStyle, "parent-style-name", name)
+			pc.automaticStyles.AppendChild(clone)
+			pc.restyled[pair] = copied
+		}
+		paragraph.SetAttr(odf.NSText, "style-name", copied)
+	}
+	pc.report.Counts[odf.DecodeStyleName(name)]++
+	return true
+}
+
+// ensureStyle makes sure styles.xml has a common paragraph style with the
+// given name, copying it from the template if there is one and otherwise
+// creating it based on basedOn, so that the text keeps its look until the
+// house template is applied. setup, if not nil, adds to a created style.
+// It returns the style's internal name.
+func (pc *ParagraphConverter) ensureStyle(name, basedOn string, setup func(*xmltree.Node)) (string, error) {
+	return pc.ensureFamilyStyle("paragraph", name, basedOn, setup)
+}
+
+// ensureFamilyStyle is ensureStyle for a style of any family
+func (pc *ParagraphConverter) ensureFamilyStyle(family, name, basedOn string, setup func(*xmltree.Node)) (string, error) {
+	if style := findStyle(pc.commonStyles, family, name); style != nil {
+		return style.AttrValue(odf.NSStyle, "name"), nil
+	}
+
+	internal := odf.EncodeStyleName(name)
+	if pc.template != nil {
+		key := odf.StyleKey{Family: family, Name: internal}
+		result, err := pc.doc.ImportStyles(pc.template, []odf.StyleKey{key}, odf.ImportKeep)
+		if err != nil {
+			return "", err
+		}
+		if len(result.Missing) == 0 {
+			pc.report.Imported = append(pc.report.Imported, name)
+			return internal, nil
+		}
+	}
+
+	style := xmltree.NewElement(xml.Name{Space: odf.NSStyle, Local: "style"})
+	style.SetAttr(odf.NSStyle, "name", internal)
+	if odf.NeedsEncoding(name) {
+		style.SetAttr(odf.NSStyle, "display-name", name)
+	}
+	style.SetAttr(odf.NSStyle, "family", family)
+	if basedOn != "" {
+		style.SetAttr(odf.NSStyle, "parent-style-name", basedOn)
+	}
+	if setup != nil {
+		setup(style)
+	}
+	pc.commonStyles.AppendChild(style)
+	pc.report.Created = append(pc.report.Created, name)
+	return internal, nil
+}
+
+// findStyle looks for a style of the given family in an office:styles
+// element by internal or display name
+func findStyle(styles *xmltree.Node, family, name string) *xmltree.Node {
+	for _, style := range styles.Elements() {
+		if !style.Is(odf.NSStyle, "style") || style.AttrValue(odf.NSStyle, "family") != family {
+			continue
+		}
+		if style.AttrValue(odf.NSStyle, "name") == odf.EncodeStyleName(name) || style.AttrValue(odf.NSStyle, "display-name") == name {
+			return style
+		}
+	}
+	return nil
+}
+
+// excerpt returns the start of a paragraph's text, for reports
+func excerpt(paragraph *xmltree.Node) string {
+	text := strings.TrimSpace(paragraph.Text())
+	if runes := []rune(text); len(runes) > 40 {
+		text = string(runes[:40]) + "..."
+	}
+	return strconv.Quote(text)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"LibreOfficeReformatter/odf"
+)
+
+func TestSetStyleCountsChanges(t *testing.T) {
+	pc := testConverter(t,
+		`<office:styles>`+
+			`<style:style style:name="Body" style:family="paragraph"/>`+
+			`<style:style style:name="Text_20_body" style:display-name="Text body" style:family="paragraph"/>`+
+			`</office:styles>`,
+		`<office:automatic-styles>`+
+			`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Body"><style:paragraph-properties fo:margin-top="1in"/></style:style>`+
+			`<style:style style:name="P2" style:family="paragraph" style:parent-style-name="Text_20_body"><style:paragraph-properties fo:margin-top="1in"/></style:style>`+
+			`</office:automatic-styles>`+
+			`<office:body><office:text>`+
+			`<text:p text:style-name="Body">already Body</text:p>`+
+			`<text:p text:style-name="P1">Body through an automatic style</text:p>`+
+			`<text:p text:style-name="Text_20_body">Text body</text:p>`+
+			`<text:p text:style-name="P2">Text body through an automatic style</text:p>`+
+			`<text:p>no style</text:p>`+
+			`</office:text></office:body>`)
+
+	tests := []struct {
+		name    string
+		changed bool
+		want    string // style afterwards
+	}{
+		{"already Body", false, "Body"},
+		{"Body through an automatic style", false, "P1"},
+		{"Text body", true, "Body"},
+		{"Text body through an automatic style", true, "P3"},
+		{"no style", true, "Body"},
+	}
+
+	paragraphs := pc.bodyParagraphs()
+	if len(paragraphs) != len(tests) {
+		t.Fatalf("%d paragraphs, want %d", len(paragraphs), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if changed := pc.setStyle(paragraphs[i], "Body"); changed != tt.changed {
+				t.Errorf("setStyle changed = %v, want %v", changed, tt.changed)
+			}
+			if got := paragraphs[i].AttrValue(odf.NSText, "style-name"); got != tt.want {
+				t.Errorf("style = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if got := pc.report.Counts["Body"]; got != 3 {
+		t.Errorf("counted %d paragraphs as Body, want 3", got)
+	}
+}
@@ -0,0 +1,123 @@
+package odf
+
+import (
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// definitionSections are the children of a styles.xml or content.xml root
+// that hold style definitions
+var definitionSections = []string{"font-face-decls", "styles", "automatic-styles", "master-styles"}
+
+// DefinitionKey returns the style an element defines, if it is a style
+// definition such as style:style, text:list-style or style:font-face
+func DefinitionKey(n *xmltree.Node) (StyleKey, bool) {
+	if n.Kind != xmltree.ElementNode {
+		return StyleKey{}, false
+	}
+	for _, attr := range n.Attrs {
+		if ref, ok := LookupStyleRef(n, attr.Name); ok && ref.Defines {
+			return StyleKey{Family: ref.Family, Name: attr.Value}, true
+		}
+	}
+	return StyleKey{}, false
+}
+
+// Definitions maps each style defined at the top level of a part's
+// definition sections to its element
+func Definitions(root *xmltree.Node) map[StyleKey]*xmltree.Node {
+	defs := make(map[StyleKey]*xmltree.Node)
+	for _, local := range definitionSections {
+		section := root.Child(NSOffice, local)
+		if section == nil {
+			continue
+		}
+		for _, n := range section.Elements() {
+			if key, ok := DefinitionKey(n); ok {
+				defs[key] = n
+			}
+		}
+	}
+	return defs
+}
+
+// References returns the styles referred to by n and everything below it
+func References(n *xmltree.Node) []StyleKey {
+	var keys []StyleKey
+	n.Walk(func(c *xmltree.Node) bool {
+		if c.Kind != xmltree.ElementNode {
+			return false
+		}
+		for _, attr := range c.Attrs {
+			ref, ok := LookupStyleRef(c, attr.Name)
+			if !ok || ref.Defines {
+				continue
+			}
+			for _, name := range ref.StyleNames(attr.Value) {
+				keys = append(keys, StyleKey{Family: ref.Family, Name: name})
+			}
+		}
+		return true
+	})
+	return keys
+}
+
+// SameDefinition reports whether two style definitions have the same
+// properties, ignoring their names
+func SameDefinition(a, b *xmltree.Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !a.Is(b.Name.Space, b.Name.Local) || !equalAttrs(definitionAttrs(a), definitionAttrs(b)) {
+		return false
+	}
+	var aChildren, bChildren strings.Builder
+	for _, c := range a.Children {
+		aChildren.WriteString(c.String())
+	}
+	for _, c := range b.Children {
+		bChildren.WriteString(c.String())
+	}
+	return aChildren.String() == bChildren.String()
+}
+
+// DisplayName returns the display name of a style definition. It is in the
+// namespace of the definition's name attribute: style:display-name even for
+// text:list-style and number:date-style, draw:display-name for gradients.
+func DisplayName(definition *xmltree.Node) string {
+	for _, attr := range definition.Attrs {
+		if ref, ok := LookupStyleRef(definition, attr.Name); ok && ref.Defines {
+			return definition.AttrValue(attr.Name.Space, "display-name")
+		}
+	}
+	return ""
+}
+
+// definitionAttrs collects the attributes of a definition other than its
+// name and display name
+func definitionAttrs(definition *xmltree.Node) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range definition.Attrs {
+		if ref, ok := LookupStyleRef(definition, attr.Name); ok && ref.Defines {
+			continue
+		}
+		if attr.Name.Local == "display-name" {
+			continue
+		}
+		attrs[attr.Name.Space+" "+attr.Name.Local] = attr.Value
+	}
+	return attrs
+}
+
+func equalAttrs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
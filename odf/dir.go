@@ -0,0 +1,142 @@
+package odf
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// storedExtensions are already compressed, so deflating them again is wasted work
+var storedExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+	".svgz": true, ".wmz": true, ".emz": true, ".zip": true,
+	".odt": true, ".ods": true, ".odp": true, ".odg": true, ".odf": true,
+}
+
+// localPath converts an entry name to a path below dir, refusing names
+// that would escape it ("zip slip")
+func localPath(dir, name string) (string, error) {
+	rel := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if strings.Contains(name, `\`) || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("refusing unsafe entry name %q", name)
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+// Extract writes every entry of the package below dir, which must be empty
+// or not exist yet, and restores each entry's modification time
+func (p *Package) Extract(dir string) error {
+	if existing, err := os.ReadDir(dir); err == nil && len(existing) > 0 {
+		return fmt.Errorf("%s is not empty", dir)
+	}
+	// Check every name before writing anything
+	for _, e := range p.Entries {
+		if _, err := localPath(dir, e.Name); err != nil {
+			return err
+		}
+		if e.file != nil && !e.file.Mode().IsRegular() && !e.file.Mode().IsDir() {
+			return fmt.Errorf("refusing special file %s", e.Name)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	var dirs []*Entry
+	for _, e := range p.Entries {
+		target, _ := localPath(dir, e.Name)
+
+		if e.IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+			dirs = append(dirs, e)
+			continue
+		}
+
+		data, err := e.Data()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		if err := os.Chtimes(target, e.Modified, e.Modified); err != nil {
+			return fmt.Errorf("failed to set time on %s: %w", target, err)
+		}
+	}
+
+	// Directory times last, since writing their files changed them
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target, _ := localPath(dir, dirs[i].Name)
+		if err := os.Chtimes(target, dirs[i].Modified, dirs[i].Modified); err != nil {
+			return fmt.Errorf("failed to set time on %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// ReadDir builds a package from an extracted directory. Files keep their
+// modification times, empty directories become directory entries, and
+// already-compressed media are stored rather than deflated. Whitespace an
+// editor may have added to the mimetype file is trimmed.
+func ReadDir(dir string) (*Package, error) {
+	pkg := New()
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			children, err := os.ReadDir(filePath)
+			if err != nil {
+				return err
+			}
+			if len(children) == 0 {
+				pkg.Entries = append(pkg.Entries, &Entry{Name: name + "/", Method: zip.Store, Modified: info.ModTime()})
+			}
+			return nil
+		case !info.Mode().IsRegular():
+			return fmt.Errorf("refusing special file %s", filePath)
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		method := zip.Deflate
+		if name == MimetypePath {
+			data = []byte(strings.TrimSpace(string(data)))
+			method = zip.Store
+		} else if storedExtensions[strings.ToLower(path.Ext(name))] {
+			method = zip.Store
+		}
+		pkg.Entries = append(pkg.Entries, &Entry{Name: name, Method: method, Modified: info.ModTime(), data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	return pkg, nil
+}
@@ -0,0 +1,86 @@
+package odf
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// Document is a package whose XML parts are parsed on demand. Parts are
+// written back on Save only if their serialised form has changed, so
+// untouched parts are copied through byte for byte.
+type Document struct {
+	Package *Package
+	parts   map[string]*xmltree.Document
+}
+
+// OpenDocument opens a package for editing its XML parts
+func OpenDocument(path string) (*Document, error) {
+	pkg, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Package: pkg, parts: make(map[string]*xmltree.Document)}, nil
+}
+
+// Close releases the underlying package
+func (d *Document) Close() error {
+	return d.Package.Close()
+}
+
+// Part returns the parsed XML part, or nil if the package doesn't have it
+func (d *Document) Part(name string) (*xmltree.Document, error) {
+	if part, ok := d.parts[name]; ok {
+		return part, nil
+	}
+	if d.Package.Entry(name) == nil {
+		return nil, nil
+	}
+	data, err := d.Package.ReadEntry(name)
+	if err != nil {
+		return nil, err
+	}
+	part, err := xmltree.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	d.parts[name] = part
+	return part, nil
+}
+
+// SubDocuments returns the directory prefix of the main document ("") and of
+// every embedded "Object N/" sub-document that has XML parts of its own
+func (d *Document) SubDocuments() []string {
+	prefixes := []string{""}
+	seen := map[string]bool{"": true}
+	for _, e := range d.Package.DocumentParts() {
+		prefix := path.Dir(e.Name) + "/"
+		if prefix == "./" || seen[prefix] || strings.HasPrefix(prefix, "Configurations2/") {
+			continue
+		}
+		seen[prefix] = true
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// Save serialises the parsed parts and writes the package to path
+func (d *Document) Save(path string) error {
+	for name, part := range d.parts {
+		data, err := part.Bytes()
+		if err != nil {
+			return err
+		}
+		entry := d.Package.Entry(name)
+		original, err := entry.Data()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(original, data) {
+			entry.SetData(data)
+		}
+	}
+	return d.Package.WriteFile(path)
+}
@@ -0,0 +1,36 @@
+package odf
+
+import (
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// FontFace is a style:font-face declaration from office:font-face-decls.
+// Text properties name a font face with style:font-name rather than giving
+// the font family directly.
+type FontFace struct {
+	Name    string
+	Family  string // the first family in svg:font-family, unquoted
+	Pitch   string // style:font-pitch, "fixed" or "variable" if declared
+	Generic string // style:font-family-generic, such as "modern" or "swiss"
+	Node    *xmltree.Node
+}
+
+// NewFontFace reads a style:font-face element
+func NewFontFace(n *xmltree.Node) *FontFace {
+	return &FontFace{
+		Name:    n.AttrValue(NSStyle, "name"),
+		Family:  FirstFontFamily(n.AttrValue(NSSvg, "font-family")),
+		Pitch:   n.AttrValue(NSStyle, "font-pitch"),
+		Generic: n.AttrValue(NSStyle, "font-family-generic"),
+		Node:    n,
+	}
+}
+
+// FirstFontFamily returns the first family of a CSS-style list such as
+// "FuturaPT-Book, 'Century Gothic'", without its quotes
+func FirstFontFamily(families string) string {
+	first, _, _ := strings.Cut(families, ",")
+	return strings.Trim(strings.TrimSpace(first), `'"`)
+}
@@ -0,0 +1,194 @@
+package odf
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// Direct formatting rarely shows up in the paragraph itself: LibreOffice
+// gives each formatted span an automatic text style (T7) that carries
+// fo:font-weight and the like, so the formatting of a run of text has to be
+// worked out from the styles of the spans around it and of its paragraph.
+
+// Text properties that decide which font text is in
+var (
+	FontName   = xml.Name{Space: NSStyle, Local: "font-name"}
+	FontFamily = xml.Name{Space: NSFo, Local: "font-family"}
+	FontPitch  = xml.Name{Space: NSStyle, Local: "font-pitch"}
+)
+
+// Text properties text is classified by
+var (
+	FontWeight   = xml.Name{Space: NSFo, Local: "font-weight"}
+	FontStyle    = xml.Name{Space: NSFo, Local: "font-style"}
+	FontVariant  = xml.Name{Space: NSFo, Local: "font-variant"}
+	TextPosition = xml.Name{Space: NSStyle, Local: "text-position"}
+	Underline    = xml.Name{Space: NSStyle, Local: "text-underline-style"}
+	LineThrough  = xml.Name{Space: NSStyle, Local: "text-line-through-style"}
+	Color        = xml.Name{Space: NSFo, Local: "color"}
+)
+
+// TextFormat is the character formatting in effect for a run of text
+type TextFormat struct {
+	Bold      bool
+	Italic    bool
+	Position  string // "super", "sub", or "" on the baseline
+	Monospace bool
+	SmallCaps bool
+	Underline bool
+	Strike    bool
+	Color     string // fo:color, "" if not set
+}
+
+// DefaultLiteralFonts are the fixed-width families authors use for code,
+// for documents whose font faces don't declare a pitch
+var DefaultLiteralFonts = []string{"Courier", "Courier New", "Liberation Mono", "DejaVu Sans Mono", "Consolas", "Menlo", "Monaco"}
+
+// textStyleFamily returns the family of the style an element's
+// text:style-name refers to, if the element formats the text inside it
+func textStyleFamily(n *xmltree.Node) (string, bool) {
+	switch {
+	case n.Is(NSText, "span"), n.Is(NSText, "a"):
+		return "text", true
+	case n.Is(NSText, "p"), n.Is(NSText, "h"):
+		return "paragraph", true
+	}
+	return "", false
+}
+
+// TextProperty returns the value of a text property in effect at n in a
+// part: from the innermost span that sets it, then the paragraph style and
+// its parents, then the default paragraph style
+func (s *Styles) TextProperty(part string, n *xmltree.Node, attr xml.Name) string {
+	for ; n != nil; n = n.Parent {
+		family, ok := textStyleFamily(n)
+		if !ok {
+			continue
+		}
+		if name, ok := n.Attr(NSText, "style-name"); ok {
+			def := s.Lookup(part, StyleKey{Family: family, Name: name})
+			if value, ok := s.Defined(def, "text-properties", attr); ok {
+				return value
+			}
+		}
+		if family == "paragraph" {
+			break
+		}
+	}
+	value, _ := s.Default("paragraph", "text-properties", attr)
+	return value
+}
+
+// Monospace reports whether the font in effect at n is a fixed-width one:
+// its font face is declared with style:font-pitch="fixed", or its family is
+// one of the literal fonts. Declared pitches can't be relied on alone, as
+// documents converted from Word often declare Courier as variable.
+func (s *Styles) Monospace(part string, n *xmltree.Node, literalFonts []string) bool {
+	if s.TextProperty(part, n, FontPitch) == "fixed" {
+		return true
+	}
+
+	family := FirstFontFamily(s.TextProperty(part, n, FontFamily))
+	if face := s.FontFace(s.TextProperty(part, n, FontName)); face != nil {
+		if face.Pitch == "fixed" {
+			return true
+		}
+		family = face.Family
+	}
+	for _, literal := range literalFonts {
+		if strings.EqualFold(family, literal) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatAt returns the formatting in effect at n in a part
+func (s *Styles) FormatAt(part string, n *xmltree.Node, literalFonts []string) TextFormat {
+	return TextFormat{
+		Bold:      isBold(s.TextProperty(part, n, FontWeight)),
+		Italic:    isItalic(s.TextProperty(part, n, FontStyle)),
+		Position:  position(s.TextProperty(part, n, TextPosition)),
+		Monospace: s.Monospace(part, n, literalFonts),
+		SmallCaps: s.TextProperty(part, n, FontVariant) == "small-caps",
+		Underline: isLine(s.TextProperty(part, n, Underline)),
+		Strike:    isLine(s.TextProperty(part, n, LineThrough)),
+		Color:     s.TextProperty(part, n, Color),
+	}
+}
+
+// DirectFormat returns the formatting a span adds to the text around it. A
+// span that is bold because its paragraph is a bold heading adds nothing.
+func (s *Styles) DirectFormat(part string, span *xmltree.Node, literalFonts []string) TextFormat {
+	own, around := s.FormatAt(part, span, literalFonts), s.FormatAt(part, span.Parent, literalFonts)
+	format := TextFormat{
+		Bold:      own.Bold && !around.Bold,
+		Italic:    own.Italic && !around.Italic,
+		Monospace: own.Monospace && !around.Monospace,
+		SmallCaps: own.SmallCaps && !around.SmallCaps,
+		Underline: own.Underline && !around.Underline,
+		Strike:    own.Strike && !around.Strike,
+	}
+	if own.Position != around.Position {
+		format.Position = own.Position
+	}
+	if ownColor := plainColor(own.Color); !strings.EqualFold(ownColor, plainColor(around.Color)) {
+		format.Color = ownColor
+	}
+	return format
+}
+
+// plainColor treats black as the automatic colour, which documents write
+// as #000000, auto or not at all, so that black text over a default colour
+// doesn't count as formatting
+func plainColor(color string) string {
+	if strings.EqualFold(color, "#000000") || color == "auto" {
+		return ""
+	}
+	return color
+}
+
+// isBold reads fo:font-weight, which is "normal", "bold" or 100 to 900
+func isBold(weight string) bool {
+	if weight == "bold" {
+		return true
+	}
+	n, err := strconv.Atoi(weight)
+	return err == nil && n >= 600
+}
+
+// isItalic reads fo:font-style
+func isItalic(style string) bool {
+	return style == "italic" || style == "oblique"
+}
+
+// isLine reads an underline or strike-through style, where "none" or no
+// value at all means there isn't one
+func isLine(style string) bool {
+	return style != "" && style != "none"
+}
+
+// position reads style:text-position, which is "super", "sub" or a
+// percentage raise, followed by an optional font size
+func position(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	switch fields[0] {
+	case "super", "sub":
+		return fields[0]
+	}
+	raise, err := strconv.ParseFloat(strings.TrimSuffix(fields[0], "%"), 64)
+	switch {
+	case err != nil || raise == 0:
+		return ""
+	case raise > 0:
+		return "super"
+	default:
+		return "sub"
+	}
+}
@@ -0,0 +1,86 @@
+package odf
+
+import (
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestFormatAt(t *testing.T) {
+	const styles = `<office:font-face-decls>` +
+		`<style:font-face style:name="Code" svg:font-family="'Source Code Pro'" style:font-pitch="fixed"/>` +
+		`<style:font-face style:name="Courier New" svg:font-family="'Courier New'" style:font-pitch="variable"/>` +
+		`</office:font-face-decls><office:styles>` +
+		`<style:default-style style:family="paragraph"><style:text-properties fo:color="#000000"/></style:default-style>` +
+		`<style:style style:name="Heading" style:family="paragraph"><style:text-properties fo:font-weight="bold"/></style:style>` +
+		`<style:style style:name="Heading_20_1" style:family="paragraph" style:parent-style-name="Heading"/>` +
+		`<style:style style:name="Emphasis" style:family="text"><style:text-properties fo:font-style="italic"/></style:style>` +
+		`</office:styles>`
+	const automatic = `<office:automatic-styles>` +
+		`<style:style style:name="T1" style:family="text"><style:text-properties fo:font-weight="bold"/></style:style>` +
+		`<style:style style:name="T2" style:family="text" style:parent-style-name="Emphasis"><style:text-properties style:text-position="super 58%"/></style:style>` +
+		`<style:style style:name="T3" style:family="text"><style:text-properties style:font-name="Code"/></style:style>` +
+		`<style:style style:name="T4" style:family="text"><style:text-properties style:font-name="Courier New"/></style:style>` +
+		`<style:style style:name="T5" style:family="text"><style:text-properties fo:font-family="Monaco, monospace"/></style:style>` +
+		`<style:style style:name="T6" style:family="text"><style:text-properties fo:font-variant="small-caps" style:text-underline-style="solid" style:text-line-through-style="none"/></style:style>` +
+		`<style:style style:name="T7" style:family="text"><style:text-properties fo:color="#c9211e"/></style:style>` +
+		`<style:style style:name="T8" style:family="text"><style:text-properties fo:font-weight="normal" style:text-position="0% 100%"/></style:style>` +
+		`</office:automatic-styles>`
+
+	tests := []struct {
+		name   string
+		p      string // the text:p; the format is taken at its first span
+		at     TextFormat
+		direct TextFormat
+	}{
+		{"bold span", `<text:p><text:span text:style-name="T1">x</text:span></text:p>`, TextFormat{Bold: true, Color: "#000000"}, TextFormat{Bold: true}},
+		{
+			"bold span in a bold heading adds nothing",
+			`<text:h text:style-name="Heading_20_1"><text:span text:style-name="T1">x</text:span></text:h>`,
+			TextFormat{Bold: true, Color: "#000000"}, TextFormat{},
+		},
+		{
+			"italic from the common parent, superscript from the span",
+			`<text:p><text:span text:style-name="T2">x</text:span></text:p>`,
+			TextFormat{Italic: true, Position: "super", Color: "#000000"}, TextFormat{Italic: true, Position: "super"},
+		},
+		{
+			"inner span adds to the outer",
+			`<text:p><text:span text:style-name="T1"><text:span text:style-name="Emphasis">x</text:span></text:span></text:p>`,
+			TextFormat{Bold: true, Italic: true, Color: "#000000"}, TextFormat{Italic: true},
+		},
+		{"fixed pitch face", `<text:p><text:span text:style-name="T3">x</text:span></text:p>`, TextFormat{Monospace: true, Color: "#000000"}, TextFormat{Monospace: true}},
+		{"literal font declared variable", `<text:p><text:span text:style-name="T4">x</text:span></text:p>`, TextFormat{Monospace: true, Color: "#000000"}, TextFormat{Monospace: true}},
+		{"literal font family", `<text:p><text:span text:style-name="T5">x</text:span></text:p>`, TextFormat{Monospace: true, Color: "#000000"}, TextFormat{Monospace: true}},
+		{
+			"small caps and lines",
+			`<text:p><text:span text:style-name="T6">x</text:span></text:p>`,
+			TextFormat{SmallCaps: true, Underline: true, Color: "#000000"}, TextFormat{SmallCaps: true, Underline: true},
+		},
+		{"colour", `<text:p><text:span text:style-name="T7">x</text:span></text:p>`, TextFormat{Color: "#c9211e"}, TextFormat{Color: "#c9211e"}},
+		{
+			"normal weight and baseline in a heading",
+			`<text:h text:style-name="Heading_20_1"><text:span text:style-name="T8">x</text:span></text:h>`,
+			TextFormat{Color: "#000000"}, TextFormat{},
+		},
+		{"unstyled span", `<text:p><text:span>x</text:span></text:p>`, TextFormat{Color: "#000000"}, TextFormat{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := parseTestPart(t, automatic+`<office:body><office:text>`+tt.p+`</office:text></office:body>`)
+			s := NewStyles(map[string]*xmltree.Document{"styles.xml": parseTestPart(t, styles), "content.xml": content})
+			span := findElement(content.Root, "text:span")
+			for span.Children[0].Kind == xmltree.ElementNode {
+				span = span.Children[0]
+			}
+
+			if got := s.FormatAt("content.xml", span, DefaultLiteralFonts); got != tt.at {
+				t.Errorf("FormatAt = %+v, want %+v", got, tt.at)
+			}
+			if got := s.DirectFormat("content.xml", span, DefaultLiteralFonts); got != tt.direct {
+				t.Errorf("DirectFormat = %+v, want %+v", got, tt.direct)
+			}
+		})
+	}
+}
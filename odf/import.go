@@ -0,0 +1,208 @@
+package odf
+
+import (
+	"fmt"
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// ImportPolicy says what to do with a style both documents define
+type ImportPolicy string
+
+const (
+	ImportKeep      ImportPolicy = "keep"      // leave the document's definition alone
+	ImportOverwrite ImportPolicy = "overwrite" // replace it with the template's
+	ImportReport    ImportPolicy = "report"    // leave it alone, for the caller to show both definitions
+)
+
+// ImportResult lists what an import did
+type ImportResult struct {
+	Imported    []StyleKey          // copied from the template
+	Overwritten []StyleKey          // replaced by the template's definition
+	Differing   []StyleKey          // defined differently in both and left alone
+	Missing     []StyleKey          // asked for but not defined by the template
+	Renamed     map[StyleKey]string // automatic styles copied under a new name
+}
+
+// ImportStyles copies the definitions of the given styles from the
+// template's styles.xml into the document's, together with everything they
+// depend on: parent and next styles, list styles, master pages and their
+// page layouts, font faces and so on. Automatic styles, such as page
+// layouts, whose names are taken by different styles in the document are
+// copied under a new name.
+func (d *Document) ImportStyles(template *Document, keys []StyleKey, policy ImportPolicy) (*ImportResult, error) {
+	source, err := template.Part("styles.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read styles.xml of the template: %w", err)
+	}
+	if source == nil {
+		return nil, fmt.Errorf("the template has no styles.xml")
+	}
+	target, err := d.Part("styles.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read styles.xml: %w", err)
+	}
+	if target == nil {
+		return nil, fmt.Errorf("the document has no styles.xml")
+	}
+
+	im := &importer{
+		source:  Definitions(source.Root),
+		target:  Definitions(target.Root),
+		policy:  policy,
+		result:  &ImportResult{Renamed: make(map[StyleKey]string)},
+		visited: make(map[StyleKey]bool),
+		taken:   make(map[StyleKey]bool),
+	}
+	for _, key := range keys {
+		if im.source[key] == nil {
+			im.result.Missing = append(im.result.Missing, key)
+			continue
+		}
+		im.visit(key)
+	}
+
+	for _, key := range im.plan {
+		if err := im.copy(key, target.Root); err != nil {
+			return nil, err
+		}
+	}
+	return im.result, nil
+}
+
+// importer works out which definitions to copy before copying any, so that
+// renamed automatic styles can be fixed up in everything that refers to them
+type importer struct {
+	source, target map[StyleKey]*xmltree.Node
+	policy         ImportPolicy
+	result         *ImportResult
+	visited        map[StyleKey]bool
+	taken          map[StyleKey]bool // new names already given to renamed styles
+	plan           []StyleKey
+}
+
+// visit plans the import of a style and, unless the document's own
+// definition is kept, of the styles it refers to
+func (im *importer) visit(key StyleKey) {
+	if im.visited[key] {
+		return
+	}
+	im.visited[key] = true
+
+	definition := im.source[key]
+	if definition == nil {
+		// Probably defined by the document itself, or built in
+		return
+	}
+	existing := im.target[key]
+	switch {
+	case existing == nil:
+		im.result.Imported = append(im.result.Imported, key)
+	case SameDefinition(existing, definition):
+		return
+	case isAutomatic(definition):
+		im.result.Renamed[key] = im.freeName(key)
+		im.result.Imported = append(im.result.Imported, key)
+	case im.policy == ImportOverwrite:
+		im.result.Overwritten = append(im.result.Overwritten, key)
+	default:
+		im.result.Differing = append(im.result.Differing, key)
+		return
+	}
+
+	im.plan = append(im.plan, key)
+	for _, ref := range References(definition) {
+		im.visit(ref)
+	}
+}
+
+// freeName finds a name for an automatic style that neither document nor
+// an earlier rename in this import uses, numbering on from its own the way
+// LibreOffice does: Mpm1 to Mpm2
+func (im *importer) freeName(key StyleKey) string {
+	base := strings.TrimRight(key.Name, "0123456789")
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s%d", base, n)
+		candidate := StyleKey{Family: key.Family, Name: name}
+		if im.source[candidate] == nil && im.target[candidate] == nil && !im.taken[candidate] {
+			im.taken[candidate] = true
+			return name
+		}
+	}
+}
+
+// copy puts a clone of the template's definition in the matching section
+// of the document, replacing the document's own if it is overwritten
+func (im *importer) copy(key StyleKey, root *xmltree.Node) error {
+	definition := im.source[key]
+	clone := definition.Clone()
+	im.rename(clone)
+
+	if existing := im.target[key]; existing != nil && im.result.Renamed[key] == "" {
+		existing.Parent.Children[existing.Index()] = clone
+		clone.Parent = existing.Parent
+		existing.Parent = nil
+		return nil
+	}
+
+	section := root.Child(NSOffice, definition.Parent.Name.Local)
+	if section == nil {
+		return fmt.Errorf("styles.xml has no office:%s to import %s style '%s' into", definition.Parent.Name.Local, key.Family, key.Name)
+	}
+	section.AppendChild(clone)
+	return nil
+}
+
+// rename rewrites the names of renamed automatic styles in a copied
+// definition, both where it defines one and where it refers to one
+func (im *importer) rename(n *xmltree.Node) {
+	if len(im.result.Renamed) == 0 {
+		return
+	}
+	n.Walk(func(c *xmltree.Node) bool {
+		if c.Kind != xmltree.ElementNode {
+			return false
+		}
+		for i, attr := range c.Attrs {
+			ref, ok := LookupStyleRef(c, attr.Name)
+			if !ok {
+				continue
+			}
+			names := ref.StyleNames(attr.Value)
+			for j, name := range names {
+				if renamed, ok := im.result.Renamed[StyleKey{Family: ref.Family, Name: name}]; ok {
+					names[j] = renamed
+				}
+			}
+			c.Attrs[i].Value = strings.Join(names, " ")
+		}
+		return true
+	})
+}
+
+// isAutomatic reports whether a definition is an automatic style, whose
+// name only has to be unique within its part
+func isAutomatic(definition *xmltree.Node) bool {
+	return definition.Parent != nil && definition.Parent.Is(NSOffice, "automatic-styles")
+}
+
+// TemplateStyles returns every common style and master page the template
+// defines, for importing the whole of a house style
+func (d *Document) TemplateStyles() ([]StyleKey, error) {
+	part, err := d.Part("styles.xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read styles.xml of the template: %w", err)
+	}
+	if part == nil {
+		return nil, fmt.Errorf("the template has no styles.xml")
+	}
+	var keys []StyleKey
+	for key, definition := range Definitions(part.Root) {
+		if !isAutomatic(definition) {
+			keys = append(keys, key)
+		}
+	}
+	sortKeys(keys)
+	return keys, nil
+}
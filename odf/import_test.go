@@ -0,0 +1,187 @@
+package odf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// testDocument returns a document whose styles.xml holds the given
+// definition sections
+func testDocument(t *testing.T, styles string) *Document {
+	t.Helper()
+	return &Document{Package: New(), parts: map[string]*xmltree.Document{"styles.xml": parseTestPart(t, styles)}}
+}
+
+func TestImportStyles(t *testing.T) {
+	const fonts = `<office:font-face-decls><style:font-face style:name="Mono" svg:font-family="'Liberation Mono'" style:font-pitch="fixed"/></office:font-face-decls>`
+	const template = fonts + `<office:styles>` +
+		`<style:style style:name="CodeBase" style:family="paragraph"><style:text-properties style:font-name="Mono"/></style:style>` +
+		`<style:style style:name="Code" style:family="paragraph" style:parent-style-name="CodeBase"/>` +
+		`<style:style style:name="Body" style:family="paragraph"><style:paragraph-properties fo:margin-top="0.1in"/></style:style>` +
+		`</office:styles><office:automatic-styles>` +
+		`<style:page-layout style:name="Mpm1"><style:page-layout-properties fo:page-width="7in"/></style:page-layout>` +
+		`<style:page-layout style:name="Mpm2"><style:page-layout-properties fo:page-width="9in"/></style:page-layout>` +
+		`</office:automatic-styles><office:master-styles>` +
+		`<style:master-page style:name="Chapter" style:page-layout-name="Mpm1"/>` +
+		`<style:master-page style:name="Wide" style:page-layout-name="Mpm2"/>` +
+		`</office:master-styles>`
+
+	key := func(family, name string) StyleKey { return StyleKey{Family: family, Name: name} }
+	tests := []struct {
+		name     string
+		document string
+		keys     []StyleKey
+		policy   ImportPolicy
+		want     ImportResult
+		contains []string // in the document's styles.xml afterwards
+		lacks    []string
+	}{
+		{
+			name:     "brings parents and font faces along",
+			document: `<office:font-face-decls/><office:styles/>`,
+			keys:     []StyleKey{key("paragraph", "Code")},
+			policy:   ImportKeep,
+			want: ImportResult{
+				Imported: []StyleKey{key("paragraph", "Code"), key("paragraph", "CodeBase"), key("font-face", "Mono")},
+			},
+			contains: []string{`style:name="Code" `, `style:name="CodeBase"`, `style:name="Mono"`},
+		},
+		{
+			name:     "same definition is left alone",
+			document: `<office:styles><style:style style:name="Body" style:family="paragraph"><style:paragraph-properties fo:margin-top="0.1in"/></style:style></office:styles>`,
+			keys:     []StyleKey{key("paragraph", "Body")},
+			policy:   ImportKeep,
+			want:     ImportResult{},
+		},
+		{
+			name:     "keep reports a differing definition",
+			document: `<office:styles><style:style style:name="Body" style:family="paragraph"><style:paragraph-properties fo:margin-top="1in"/></style:style></office:styles>`,
+			keys:     []StyleKey{key("paragraph", "Body")},
+			policy:   ImportKeep,
+			want:     ImportResult{Differing: []StyleKey{key("paragraph", "Body")}},
+			contains: []string{`fo:margin-top="1in"`},
+			lacks:    []string{`fo:margin-top="0.1in"`},
+		},
+		{
+			name:     "overwrite replaces a differing definition",
+			document: `<office:styles><style:style style:name="Body" style:family="paragraph"><style:paragraph-properties fo:margin-top="1in"/></style:style></office:styles>`,
+			keys:     []StyleKey{key("paragraph", "Body")},
+			policy:   ImportOverwrite,
+			want:     ImportResult{Overwritten: []StyleKey{key("paragraph", "Body")}},
+			contains: []string{`fo:margin-top="0.1in"`},
+			lacks:    []string{`fo:margin-top="1in"`},
+		},
+		{
+			name:     "report still copies missing styles",
+			document: `<office:font-face-decls/><office:styles/>`,
+			keys:     []StyleKey{key("paragraph", "Code")},
+			policy:   ImportReport,
+			want: ImportResult{
+				Imported: []StyleKey{key("paragraph", "Code"), key("paragraph", "CodeBase"), key("font-face", "Mono")},
+			},
+			contains: []string{`style:name="Code" `, `style:name="CodeBase"`, `style:name="Mono"`},
+		},
+		{
+			name:     "report leaves a differing definition",
+			document: `<office:font-face-decls/><office:styles><style:style style:name="Body" style:family="paragraph"><style:paragraph-properties fo:margin-top="1in"/></style:style></office:styles>`,
+			keys:     []StyleKey{key("paragraph", "Body"), key("paragraph", "CodeBase")},
+			policy:   ImportReport,
+			want: ImportResult{
+				Imported:  []StyleKey{key("paragraph", "CodeBase"), key("font-face", "Mono")},
+				Differing: []StyleKey{key("paragraph", "Body")},
+			},
+			contains: []string{`fo:margin-top="1in"`, `style:name="CodeBase"`},
+			lacks:    []string{`fo:margin-top="0.1in"`},
+		},
+		{
+			name:     "missing from the template",
+			document: `<office:styles/>`,
+			keys:     []StyleKey{key("paragraph", "Nowhere")},
+			policy:   ImportKeep,
+			want:     ImportResult{Missing: []StyleKey{key("paragraph", "Nowhere")}},
+		},
+		{
+			name: "colliding automatic style is renamed",
+			document: `<office:automatic-styles>` +
+				`<style:page-layout style:name="Mpm1"><style:page-layout-properties fo:page-width="8.5in"/></style:page-layout>` +
+				`</office:automatic-styles><office:master-styles/>`,
+			keys:   []StyleKey{key("master-page", "Chapter")},
+			policy: ImportKeep,
+			want: ImportResult{
+				Imported: []StyleKey{key("master-page", "Chapter"), key("page-layout", "Mpm1")},
+				Renamed:  map[StyleKey]string{key("page-layout", "Mpm1"): "Mpm3"},
+			},
+			contains: []string{
+				`<style:page-layout style:name="Mpm1"><style:page-layout-properties fo:page-width="8.5in"/>`,
+				`<style:page-layout style:name="Mpm3"><style:page-layout-properties fo:page-width="7in"/>`,
+				`<style:master-page style:name="Chapter" style:page-layout-name="Mpm3"/>`,
+			},
+		},
+		{
+			name: "two collisions in one import get different names",
+			document: `<office:automatic-styles>` +
+				`<style:page-layout style:name="Mpm1"><style:page-layout-properties fo:page-width="8.5in"/></style:page-layout>` +
+				`<style:page-layout style:name="Mpm2"><style:page-layout-properties fo:page-width="11in"/></style:page-layout>` +
+				`</office:automatic-styles><office:master-styles/>`,
+			keys:   []StyleKey{key("master-page", "Chapter"), key("master-page", "Wide")},
+			policy: ImportKeep,
+			want: ImportResult{
+				Imported: []StyleKey{key("master-page", "Chapter"), key("page-layout", "Mpm1"), key("master-page", "Wide"), key("page-layout", "Mpm2")},
+				Renamed:  map[StyleKey]string{key("page-layout", "Mpm1"): "Mpm3", key("page-layout", "Mpm2"): "Mpm4"},
+			},
+			contains: []string{
+				`<style:master-page style:name="Chapter" style:page-layout-name="Mpm3"/>`,
+				`<style:master-page style:name="Wide" style:page-layout-name="Mpm4"/>`,
+				`<style:page-layout style:name="Mpm3"><style:page-layout-properties fo:page-width="7in"/>`,
+				`<style:page-layout style:name="Mpm4"><style:page-layout-properties fo:page-width="9in"/>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := testDocument(t, tt.document)
+			result, err := doc.ImportStyles(testDocument(t, template), tt.keys, tt.policy)
+			if err != nil {
+				t.Fatalf("ImportStyles: %v", err)
+			}
+			if tt.want.Renamed == nil {
+				tt.want.Renamed = map[StyleKey]string{}
+			}
+			if !reflect.DeepEqual(*result, tt.want) {
+				t.Errorf("result = %+v\nwant %+v", *result, tt.want)
+			}
+
+			part, _ := doc.Part("styles.xml")
+			data, err := part.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("styles.xml lacks %s:\n%s", want, data)
+				}
+			}
+			for _, unwanted := range tt.lacks {
+				if strings.Contains(string(data), unwanted) {
+					t.Errorf("styles.xml has %s:\n%s", unwanted, data)
+				}
+			}
+		})
+	}
+}
+
+func TestImportStylesNeedsStylesParts(t *testing.T) {
+	empty := &Document{Package: New(), parts: map[string]*xmltree.Document{}}
+	full := testDocument(t, `<office:styles/>`)
+
+	if _, err := full.ImportStyles(empty, nil, ImportKeep); err == nil || !strings.Contains(err.Error(), "template has no styles.xml") {
+		t.Errorf("import from a template without styles.xml: error %v", err)
+	}
+	if _, err := empty.ImportStyles(full, nil, ImportKeep); err == nil || !strings.Contains(err.Error(), "document has no styles.xml") {
+		t.Errorf("import into a document without styles.xml: error %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package odf
+
+import (
+	"encoding/xml"
+	"sort"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// StyleKey identifies a style by family and internal name
+type StyleKey struct {
+	Family string
+	Name   string
+}
+
+// StyleSite is an attribute where a style is defined or referenced
+type StyleSite struct {
+	Part    string
+	Element *xmltree.Node
+	Attr    xml.Name
+}
+
+// StyleIndex records where each style of a document is defined and referenced
+type StyleIndex struct {
+	Definitions map[StyleKey][]StyleSite
+	References  map[StyleKey][]StyleSite
+}
+
+// IndexStyles walks styles.xml and content.xml of the main document
+// (prefix "") or of an embedded sub-document (prefix "Object 1/")
+func (d *Document) IndexStyles(prefix string) (*StyleIndex, error) {
+	index := &StyleIndex{
+		Definitions: make(map[StyleKey][]StyleSite),
+		References:  make(map[StyleKey][]StyleSite),
+	}
+
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		part, err := d.Part(prefix + name)
+		if err != nil {
+			return nil, err
+		}
+		if part == nil {
+			continue
+		}
+		part.Root.Walk(func(n *xmltree.Node) bool {
+			if n.Kind != xmltree.ElementNode {
+				return false
+			}
+			for _, attr := range n.Attrs {
+				ref, ok := LookupStyleRef(n, attr.Name)
+				if !ok {
+					continue
+				}
+				site := StyleSite{Part: prefix + name, Element: n, Attr: attr.Name}
+				for _, styleName := range ref.StyleNames(attr.Value) {
+					key := StyleKey{Family: ref.Family, Name: styleName}
+					if ref.Defines {
+						index.Definitions[key] = append(index.Definitions[key], site)
+					} else {
+						index.References[key] = append(index.References[key], site)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return index, nil
+}
+
+// Dangling returns the referenced styles that have no definition
+func (ix *StyleIndex) Dangling() []StyleKey {
+	var dangling []StyleKey
+	for key := range ix.References {
+		if key.Name != "" && len(ix.Definitions[key]) == 0 {
+			dangling = append(dangling, key)
+		}
+	}
+	sortKeys(dangling)
+	return dangling
+}
+
+// Keys returns every defined style, sorted by family and name
+func (ix *StyleIndex) Keys() []StyleKey {
+	var keys []StyleKey
+	for key := range ix.Definitions {
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+	return keys
+}
+
+func sortKeys(keys []StyleKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Family != keys[j].Family {
+			return keys[i].Family < keys[j].Family
+		}
+		return keys[i].Name < keys[j].Name
+	})
+}
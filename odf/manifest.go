@@ -0,0 +1,279 @@
+package odf
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// FileEntry is one manifest:file-entry in META-INF/manifest.xml
+type FileEntry struct {
+	FullPath  string `xml:"full-path,attr"`
+	MediaType string `xml:"media-type,attr"`
+	Version   string `xml:"version,attr,omitempty"`
+}
+
+// Manifest is the package manifest, reduced to what's needed to check it
+type Manifest struct {
+	XMLName xml.Name    `xml:"manifest"`
+	Version string      `xml:"version,attr,omitempty"`
+	Entries []FileEntry `xml:"file-entry"`
+}
+
+// ParseManifest decodes the contents of META-INF/manifest.xml
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestPath, err)
+	}
+	return &m, nil
+}
+
+// Manifest reads and decodes the package's manifest
+func (p *Package) Manifest() (*Manifest, error) {
+	data, err := p.ReadEntry(ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// Entry returns the manifest entry for path, or nil
+func (m *Manifest) Entry(path string) *FileEntry {
+	for i := range m.Entries {
+		if m.Entries[i].FullPath == path {
+			return &m.Entries[i]
+		}
+	}
+	return nil
+}
+
+// needsManifestEntry reports whether the spec requires a package entry
+// to be listed in the manifest. The mimetype, the manifest itself and
+// anything else under META-INF/ are exempt, as are directory entries.
+func needsManifestEntry(name string) bool {
+	return name != MimetypePath && !strings.HasPrefix(name, "META-INF/") && !strings.HasSuffix(name, "/")
+}
+
+// Verify checks that the package has a mimetype and a manifest, and that
+// the manifest lists exactly the files that are in the package
+func (p *Package) Verify() error {
+	mimetype, err := p.ReadEntry(MimetypePath)
+	if err != nil {
+		return fmt.Errorf("invalid package: %w", err)
+	}
+	manifest, err := p.Manifest()
+	if err != nil {
+		return fmt.Errorf("invalid package: %w", err)
+	}
+
+	var problems []string
+	if root := manifest.Entry("/"); root == nil {
+		problems = append(problems, `no entry for "/"`)
+	} else if root.MediaType != string(mimetype) {
+		problems = append(problems, fmt.Sprintf("root media type %q does not match mimetype %q", root.MediaType, mimetype))
+	}
+
+	for _, fe := range manifest.Entries {
+		switch {
+		case fe.FullPath == "/":
+		case strings.HasSuffix(fe.FullPath, "/"):
+			if !p.hasPrefix(fe.FullPath) {
+				problems = append(problems, fmt.Sprintf("%s is listed but has no entries", fe.FullPath))
+			}
+		case p.Entry(fe.FullPath) == nil:
+			problems = append(problems, fmt.Sprintf("%s is listed but missing", fe.FullPath))
+		}
+	}
+
+	for _, e := range p.Entries {
+		if needsManifestEntry(e.Name) && manifest.Entry(e.Name) == nil {
+			problems = append(problems, fmt.Sprintf("%s is not listed", e.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("manifest does not match package: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// hasPrefix reports whether any entry lives under the directory prefix
+func (p *Package) hasPrefix(prefix string) bool {
+	for _, e := range p.Entries {
+		if strings.HasPrefix(e.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTypes are the media types LibreOffice records for common entries
+var mediaTypes = map[string]string{
+	".xml":  "text/xml",
+	".rdf":  "application/rdf+xml",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+}
+
+// FixManifest brings the manifest into line with the package entries, as
+// needed after someone has edited an extracted directory by hand. Entries for
+// missing files are dropped, unlisted files are added, and a missing mimetype
+// is restored from the manifest's root entry. The manifest is edited in
+// place, so that encryption data, signatures' entries and attributes this
+// package doesn't model are kept. It returns what was changed.
+func (p *Package) FixManifest() ([]string, error) {
+	data := (&Manifest{Version: "1.4"}).Bytes()
+	if p.Entry(ManifestPath) != nil {
+		var err error
+		if data, err = p.ReadEntry(ManifestPath); err != nil {
+			return nil, err
+		}
+	}
+	doc, err := xmltree.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestPath, err)
+	}
+	manifest := doc.Root
+
+	var changes []string
+	mimetype, err := p.ReadEntry(MimetypePath)
+	root := fileEntry(manifest, "/")
+	switch {
+	case err != nil && root == nil:
+		return nil, fmt.Errorf("package has neither a mimetype nor a manifest root entry")
+	case err != nil:
+		mediaType := root.AttrValue(NSManifest, "media-type")
+		p.Entries = append([]*Entry{{Name: MimetypePath, Method: zip.Store, data: []byte(mediaType)}}, p.Entries...)
+		changes = append(changes, "restored mimetype from manifest")
+	case root == nil:
+		addFileEntry(manifest, "/", string(mimetype), manifest.AttrValue(NSManifest, "version"), true)
+		changes = append(changes, `added manifest entry for "/"`)
+	case root.AttrValue(NSManifest, "media-type") != string(mimetype):
+		root.SetAttr(NSManifest, "media-type", string(mimetype))
+		changes = append(changes, "set manifest root media type to "+string(mimetype))
+	}
+
+	for _, fe := range fileEntries(manifest) {
+		fullPath := fe.AttrValue(NSManifest, "full-path")
+		missing := false
+		switch {
+		case fullPath == "/":
+		case strings.HasSuffix(fullPath, "/"):
+			missing = !p.hasPrefix(fullPath)
+		default:
+			missing = p.Entry(fullPath) == nil
+		}
+		if missing {
+			removeFileEntry(fe)
+			changes = append(changes, "removed manifest entry for missing "+fullPath)
+		}
+	}
+
+	for _, e := range p.Entries {
+		if needsManifestEntry(e.Name) && fileEntry(manifest, e.Name) == nil {
+			addFileEntry(manifest, e.Name, mediaTypes[strings.ToLower(path.Ext(e.Name))], "", false)
+			changes = append(changes, "added manifest entry for "+e.Name)
+		}
+	}
+
+	if len(changes) > 0 {
+		fixed, err := doc.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", ManifestPath, err)
+		}
+		p.Add(ManifestPath, zip.Deflate, fixed)
+	}
+	return changes, nil
+}
+
+// fileEntries returns the manifest:file-entry elements of a manifest
+func fileEntries(manifest *xmltree.Node) []*xmltree.Node {
+	var entries []*xmltree.Node
+	for _, n := range manifest.Elements() {
+		if n.Is(NSManifest, "file-entry") {
+			entries = append(entries, n)
+		}
+	}
+	return entries
+}
+
+// fileEntry returns the manifest:file-entry for a path, or nil
+func fileEntry(manifest *xmltree.Node, fullPath string) *xmltree.Node {
+	for _, n := range fileEntries(manifest) {
+		if n.AttrValue(NSManifest, "full-path") == fullPath {
+			return n
+		}
+	}
+	return nil
+}
+
+// addFileEntry adds a manifest:file-entry, first or last, on a line of its
+// own as LibreOffice writes them
+func addFileEntry(manifest *xmltree.Node, fullPath, mediaType, version string, first bool) {
+	entry := xmltree.NewElement(xml.Name{Space: NSManifest, Local: "file-entry"})
+	entry.SetAttr(NSManifest, "full-path", fullPath)
+	if version != "" {
+		entry.SetAttr(NSManifest, "version", version)
+	}
+	entry.SetAttr(NSManifest, "media-type", mediaType)
+
+	at := len(manifest.Children)
+	if last := at - 1; !first && last >= 0 && manifest.Children[last].Kind == xmltree.TextNode && strings.TrimSpace(manifest.Children[last].Data) == "" {
+		at = last
+	}
+	if first {
+		at = 0
+	}
+	indent := xmltree.NewText("\n ")
+	indent.Parent, entry.Parent = manifest, manifest
+	children := append([]*xmltree.Node(nil), manifest.Children[:at]...)
+	children = append(children, indent, entry)
+	manifest.Children = append(children, manifest.Children[at:]...)
+}
+
+// removeFileEntry removes a manifest:file-entry and the line break before it
+func removeFileEntry(entry *xmltree.Node) {
+	if i := entry.Index(); i > 0 {
+		if before := entry.Parent.Children[i-1]; before.Kind == xmltree.TextNode && strings.TrimSpace(before.Data) == "" {
+			before.Remove()
+		}
+	}
+	entry.Remove()
+}
+
+// Bytes serialises the manifest the way LibreOffice writes it
+func (m *Manifest) Bytes() []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"`)
+	if m.Version != "" {
+		writeAttr(&b, "manifest:version", m.Version)
+	}
+	b.WriteString(">\n")
+	for _, fe := range m.Entries {
+		b.WriteString(" <manifest:file-entry")
+		writeAttr(&b, "manifest:full-path", fe.FullPath)
+		if fe.Version != "" {
+			writeAttr(&b, "manifest:version", fe.Version)
+		}
+		writeAttr(&b, "manifest:media-type", fe.MediaType)
+		b.WriteString("/>\n")
+	}
+	b.WriteString("</manifest:manifest>")
+	return []byte(b.String())
+}
+
+func writeAttr(b *strings.Builder, name, value string) {
+	b.WriteString(" " + name + `="`)
+	xml.EscapeText(b, []byte(value))
+	b.WriteString(`"`)
+}
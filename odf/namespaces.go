@@ -0,0 +1,66 @@
+package odf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Namespace URIs of the ODF vocabularies the tools work with. Parts are
+// matched by URI rather than prefix, since a document may bind any prefix.
+const (
+	NSOffice    = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	NSStyle     = "urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+	NSText      = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	NSTable     = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	NSDraw      = "urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"
+	NSFo        = "urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"
+	NSSvg       = "urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"
+	NSNumber    = "urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0"
+	NSChart     = "urn:oasis:names:tc:opendocument:xmlns:chart:1.0"
+	NSPresent   = "urn:oasis:names:tc:opendocument:xmlns:presentation:1.0"
+	NSDr3d      = "urn:oasis:names:tc:opendocument:xmlns:dr3d:1.0"
+	NSForm      = "urn:oasis:names:tc:opendocument:xmlns:form:1.0"
+	NSConfig    = "urn:oasis:names:tc:opendocument:xmlns:config:1.0"
+	NSManifest  = "urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"
+	NSLoext     = "urn:org:documentfoundation:names:experimental:office:xmlns:loext:1.0"
+	NSOfficeOOO = "http://openoffice.org/2009/office"
+	NSXML       = "http://www.w3.org/XML/1998/namespace"
+)
+
+// prefixes are the prefixes ODF documents conventionally bind to the
+// namespaces, for names typed by hand such as text:list-item
+var prefixes = map[string]string{
+	"office":       NSOffice,
+	"style":        NSStyle,
+	"text":         NSText,
+	"table":        NSTable,
+	"draw":         NSDraw,
+	"fo":           NSFo,
+	"svg":          NSSvg,
+	"number":       NSNumber,
+	"chart":        NSChart,
+	"presentation": NSPresent,
+	"dr3d":         NSDr3d,
+	"form":         NSForm,
+	"config":       NSConfig,
+	"manifest":     NSManifest,
+	"loext":        NSLoext,
+	"officeooo":    NSOfficeOOO,
+	"xml":          NSXML,
+}
+
+// ParseQName resolves a prefixed name such as text:list-item to its
+// namespace URI, using the conventional prefixes, so that it matches
+// elements whatever prefix a document binds
+func ParseQName(qname string) (xml.Name, error) {
+	prefix, local, found := strings.Cut(qname, ":")
+	if !found || local == "" {
+		return xml.Name{}, fmt.Errorf("'%s' is not a prefixed name", qname)
+	}
+	space, ok := prefixes[prefix]
+	if !ok {
+		return xml.Name{}, fmt.Errorf("unknown namespace prefix '%s'", prefix)
+	}
+	return xml.Name{Space: space, Local: local}, nil
+}
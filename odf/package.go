@@ -0,0 +1,301 @@
+// Package odf reads and writes OpenDocument packages (.odt, .ott, .ods ...)
+// without disturbing the parts of the archive that a tool doesn't change.
+package odf
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// MimetypePath is the entry that must come first, stored, in every package
+	MimetypePath = "mimetype"
+	// ManifestPath is the package manifest listing every other entry
+	ManifestPath = "META-INF/manifest.xml"
+)
+
+// Entry is a single file or directory inside a package
+type Entry struct {
+	Name     string
+	Method   uint16 // zip.Store or zip.Deflate
+	Modified time.Time
+
+	file *zip.File // the entry as read, copied through raw until SetData is called
+	data []byte
+}
+
+// IsDir reports whether the entry is a directory entry
+func (e *Entry) IsDir() bool {
+	return len(e.Name) > 0 && e.Name[len(e.Name)-1] == '/'
+}
+
+// Changed reports whether the entry's contents have been replaced since it was read
+func (e *Entry) Changed() bool {
+	return e.file == nil
+}
+
+// Data returns the uncompressed contents of the entry
+func (e *Entry) Data() ([]byte, error) {
+	if e.data != nil || e.file == nil {
+		return e.data, nil
+	}
+	rc, err := e.file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", e.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", e.Name, err)
+	}
+	e.data = data
+	return data, nil
+}
+
+// SetData replaces the contents of the entry, keeping its compression method
+func (e *Entry) SetData(data []byte) {
+	e.data = data
+	e.file = nil
+}
+
+// Package is an ODF package held in memory, in archive order
+type Package struct {
+	Entries []*Entry
+	reader  *zip.ReadCloser
+}
+
+// New returns an empty package
+func New() *Package {
+	return &Package{}
+}
+
+// Open reads the directory of a package. Entry contents are read on demand,
+// so the package must be closed when the caller is done with it.
+func Open(path string) (*Package, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s as a package: %w", path, err)
+	}
+
+	pkg := &Package{reader: reader}
+	for _, f := range reader.File {
+		pkg.Entries = append(pkg.Entries, &Entry{
+			Name:     f.Name,
+			Method:   f.Method,
+			Modified: f.Modified,
+			file:     f,
+		})
+	}
+	return pkg, nil
+}
+
+// Close releases the underlying archive, if any
+func (p *Package) Close() error {
+	if p.reader == nil {
+		return nil
+	}
+	err := p.reader.Close()
+	p.reader = nil
+	return err
+}
+
+// Entry returns the named entry, or nil if the package doesn't have it
+func (p *Package) Entry(name string) *Entry {
+	for _, e := range p.Entries {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// ReadEntry returns the contents of the named entry
+func (p *Package) ReadEntry(name string) ([]byte, error) {
+	e := p.Entry(name)
+	if e == nil {
+		return nil, fmt.Errorf("%s not found in package", name)
+	}
+	return e.Data()
+}
+
+// Add appends a new entry, or replaces the contents of an existing one
+func (p *Package) Add(name string, method uint16, data []byte) *Entry {
+	if e := p.Entry(name); e != nil {
+		e.SetData(data)
+		return e
+	}
+	e := &Entry{Name: name, Method: method, Modified: time.Now(), data: data}
+	p.Entries = append(p.Entries, e)
+	return e
+}
+
+// Remove drops the named entry, reporting whether it was present
+func (p *Package) Remove(name string) bool {
+	for i, e := range p.Entries {
+		if e.Name == name {
+			p.Entries = append(p.Entries[:i], p.Entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// WriteFile writes the package to path. It goes via a temporary file in the
+// same directory, so a package may safely be written over the file it was read from.
+func (p *Package) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := p.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// Write writes the package as a zip archive. The mimetype entry goes first,
+// stored and without an extra field, as the ODF spec requires; every other
+// entry follows in package order with its own compression method. Entries
+// that were never changed are copied through byte for byte.
+func (p *Package) Write(w io.Writer) error {
+	if err := p.Verify(); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	if err := p.writeMimetype(zw); err != nil {
+		return err
+	}
+
+	for _, e := range p.Entries {
+		if e.Name == MimetypePath {
+			continue
+		}
+		if err := writeEntry(zw, e); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish package: %w", err)
+	}
+	return nil
+}
+
+// writeMimetype writes the mimetype entry by hand, since zip.Writer would
+// otherwise add a data descriptor and an extended-timestamp extra field
+func (p *Package) writeMimetype(zw *zip.Writer) error {
+	e := p.Entry(MimetypePath)
+	data, err := e.Data()
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:               MimetypePath,
+		CreatorVersion:     20,
+		ReaderVersion:      20,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(data)),
+	}
+	header.ModifiedDate, header.ModifiedTime = msDosTime(e.Modified)
+
+	writer, err := zw.CreateRaw(header)
+	if err != nil {
+		return fmt.Errorf("failed to create %s in package: %w", MimetypePath, err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to package: %w", MimetypePath, err)
+	}
+	return nil
+}
+
+// writeEntry copies an unchanged entry raw, or compresses a changed one
+func writeEntry(zw *zip.Writer, e *Entry) error {
+	if e.file != nil {
+		if err := zw.Copy(e.file); err != nil {
+			return fmt.Errorf("failed to copy %s to package: %w", e.Name, err)
+		}
+		return nil
+	}
+
+	header := &zip.FileHeader{
+		Name:     e.Name,
+		Method:   e.Method,
+		Modified: e.Modified,
+	}
+	writer, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create %s in package: %w", e.Name, err)
+	}
+	if e.IsDir() {
+		return nil
+	}
+	if _, err := io.Copy(writer, bytes.NewReader(e.data)); err != nil {
+		return fmt.Errorf("failed to write %s to package: %w", e.Name, err)
+	}
+	return nil
+}
+
+// msDosTime converts a time to the MS-DOS date and time fields of a zip header
+func msDosTime(t time.Time) (date, tod uint16) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, t.Location())
+	}
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tod = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, tod
+}
+
+// documentParts are the XML streams of an ODF document, at the package
+// root or inside an embedded "Object N/" sub-document
+var documentParts = map[string]bool{
+	"content.xml":  true,
+	"styles.xml":   true,
+	"meta.xml":     true,
+	"settings.xml": true,
+}
+
+// IsDocumentPart reports whether an entry is one of the document's XML
+// streams, rather than a picture, a UI configuration file or the manifest
+func IsDocumentPart(name string) bool {
+	return documentParts[path.Base(name)] && !strings.HasPrefix(name, "META-INF/") && !strings.HasPrefix(name, "Configurations2/")
+}
+
+// DocumentParts returns the package's XML streams in archive order
+func (p *Package) DocumentParts() []*Entry {
+	var parts []*Entry
+	for _, e := range p.Entries {
+		if IsDocumentPart(e.Name) {
+			parts = append(parts, e)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,371 @@
+package odf
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMimetype = "application/vnd.oasis.opendocument.text"
+
+// testManifest lists the files the package tests put in a package
+const testManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.3">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.3" manifest:media-type="application/vnd.oasis.opendocument.text"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+func TestWriteMimetypeFirstAndStored(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []string // entries in the order they are added
+		// method the mimetype is added with, which Write must override
+		method uint16
+	}{
+		{"already first", []string{MimetypePath, ManifestPath, "content.xml"}, zip.Store},
+		{"added last", []string{"content.xml", ManifestPath, MimetypePath}, zip.Store},
+		{"added in the middle", []string{ManifestPath, MimetypePath, "content.xml"}, zip.Store},
+		{"deflated", []string{MimetypePath, ManifestPath, "content.xml"}, zip.Deflate},
+	}
+	contents := map[string]string{
+		MimetypePath:  testMimetype,
+		ManifestPath:  testManifest,
+		"content.xml": "<office:document-content/>",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			for _, name := range tt.order {
+				method := uint16(zip.Deflate)
+				if name == MimetypePath {
+					method = tt.method
+				}
+				p.Add(name, method, []byte(contents[name]))
+			}
+
+			var buf bytes.Buffer
+			if err := p.Write(&buf); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("reading the written package: %v", err)
+			}
+
+			first := zr.File[0]
+			if first.Name != MimetypePath {
+				t.Fatalf("first entry is %s, want %s", first.Name, MimetypePath)
+			}
+			if first.Method != zip.Store {
+				t.Errorf("mimetype method = %d, want stored", first.Method)
+			}
+			if len(first.Extra) != 0 {
+				t.Errorf("mimetype has a %d byte extra field", len(first.Extra))
+			}
+			if first.Flags&0x8 != 0 {
+				t.Errorf("mimetype has a data descriptor")
+			}
+			// Readers sniff the media type at a fixed offset: 30 bytes of
+			// local header and the 8 of the name "mimetype"
+			if got := string(buf.Bytes()[38 : 38+len(testMimetype)]); got != testMimetype {
+				t.Errorf("bytes at offset 38 = %q, want %q", got, testMimetype)
+			}
+
+			if len(zr.File) != len(tt.order) {
+				t.Fatalf("wrote %d entries, want %d", len(zr.File), len(tt.order))
+			}
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatalf("opening %s: %v", f.Name, err)
+				}
+				data, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatalf("reading %s: %v", f.Name, err)
+				}
+				if string(data) != contents[f.Name] {
+					t.Errorf("%s = %q, want %q", f.Name, data, contents[f.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteRejectsBadPackages(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+		want    string
+	}{
+		{"no mimetype", map[string]string{ManifestPath: testManifest, "content.xml": ""}, "mimetype not found"},
+		{"no manifest", map[string]string{MimetypePath: testMimetype, "content.xml": ""}, "manifest.xml not found"},
+		{"unlisted file", map[string]string{MimetypePath: testMimetype, ManifestPath: testManifest, "content.xml": "", "styles.xml": ""}, "styles.xml is not listed"},
+		{"listed file missing", map[string]string{MimetypePath: testMimetype, ManifestPath: testManifest}, "content.xml is listed but missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New()
+			for name, data := range tt.entries {
+				p.Add(name, zip.Deflate, []byte(data))
+			}
+			err := p.Write(io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Write error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixManifestKeepsWhatItDoesNotModel(t *testing.T) {
+	manifest := `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.3" xmlns:loext="urn:org:documentfoundation:names:experimental:office:xmlns:loext:1.0">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.3" manifest:media-type="application/vnd.oasis.opendocument.text"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml" manifest:size="1234">
+  <manifest:encryption-data manifest:checksum-type="SHA1/1K" manifest:checksum="abc="/>
+ </manifest:file-entry>
+ <manifest:file-entry manifest:full-path="gone.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+	p := New()
+	p.Add(MimetypePath, zip.Store, []byte(testMimetype))
+	p.Add(ManifestPath, zip.Deflate, []byte(manifest))
+	p.Add("content.xml", zip.Deflate, nil)
+	p.Add("Pictures/a.png", zip.Store, nil)
+
+	changes, err := p.FixManifest()
+	if err != nil {
+		t.Fatalf("FixManifest: %v", err)
+	}
+	wantChanges := []string{"removed manifest entry for missing gone.xml", "added manifest entry for Pictures/a.png"}
+	if strings.Join(changes, "\n") != strings.Join(wantChanges, "\n") {
+		t.Errorf("changes = %q, want %q", changes, wantChanges)
+	}
+
+	data, err := p.ReadEntry(ManifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := string(data)
+	for _, want := range []string{
+		`manifest:size="1234"`,
+		`<manifest:encryption-data manifest:checksum-type="SHA1/1K" manifest:checksum="abc="/>`,
+		`xmlns:loext=`,
+		`manifest:full-path="Pictures/a.png" manifest:media-type="image/png"`,
+	} {
+		if !strings.Contains(fixed, want) {
+			t.Errorf("fixed manifest lacks %s:\n%s", want, fixed)
+		}
+	}
+	if strings.Contains(fixed, "gone.xml") {
+		t.Errorf("fixed manifest still lists gone.xml:\n%s", fixed)
+	}
+	if err := p.Verify(); err != nil {
+		t.Errorf("Verify after FixManifest: %v", err)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	dir := filepath.Join("out", "doc")
+	tests := []struct {
+		name string
+		want string // "" if the name is refused
+	}{
+		{"content.xml", filepath.Join(dir, "content.xml")},
+		{"Pictures/a.png", filepath.Join(dir, "Pictures", "a.png")},
+		{"Configurations2/toolbar/", filepath.Join(dir, "Configurations2", "toolbar")},
+		{"a/./b.xml", filepath.Join(dir, "a", "b.xml")},
+		{"../x", ""},
+		{"a/../../x", ""},
+		{"..", ""},
+		{"/etc/passwd", ""},
+		{`..\x`, ""},
+		{`Pictures\a.png`, ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := localPath(dir, tt.name)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("localPath(%q) = %s, want it refused", tt.name, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("localPath(%q): %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("localPath(%q) = %s, want %s", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractRefuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    string
+		existing bool // the destination already holds a file
+		want     string
+	}{
+		{"parent directory", "../x", false, "unsafe entry name"},
+		{"absolute path", "/tmp/x", false, "unsafe entry name"},
+		{"backslashes", `..\\x`, false, "unsafe entry name"},
+		{"non-empty destination", "content.xml", true, "is not empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "doc")
+			if tt.existing {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p := New()
+			p.Add(MimetypePath, zip.Store, []byte(testMimetype))
+			p.Add(tt.entry, zip.Deflate, []byte("x"))
+
+			err := p.Extract(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Extract error = %v, want one containing %q", err, tt.want)
+			}
+			// Nothing was written, inside the destination or out of it
+			var written []string
+			filepath.WalkDir(parent, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && d.Name() != "notes.txt" {
+					written = append(written, path)
+				}
+				return nil
+			})
+			if len(written) > 0 {
+				t.Errorf("Extract wrote %q", written)
+			}
+		})
+	}
+}
+
+func TestExtractAndReadDir(t *testing.T) {
+	const manifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.3">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.3" manifest:media-type="application/vnd.oasis.opendocument.text"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+ <manifest:file-entry manifest:full-path="styles.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+	modified := time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC)
+	p := New()
+	for _, e := range []struct{ name, data string }{
+		{MimetypePath, testMimetype},
+		{ManifestPath, manifest},
+		{"content.xml", "<office:document-content/>"},
+		{"styles.xml", "<office:document-styles/>"},
+		{"Configurations2/toolbar/", ""},
+	} {
+		method := uint16(zip.Deflate)
+		if e.name == MimetypePath || strings.HasSuffix(e.name, "/") {
+			method = zip.Store
+		}
+		p.Add(e.name, method, []byte(e.data)).Modified = modified
+	}
+
+	dir := filepath.Join(t.TempDir(), "doc")
+	if err := p.Extract(dir); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	for _, name := range []string{"content.xml", "Configurations2/toolbar"} {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(modified) {
+			t.Errorf("%s modified %v, want %v", name, info.ModTime(), modified)
+		}
+	}
+
+	// Edit the directory by hand: add a picture, delete styles.xml and let
+	// an editor add a newline to mimetype
+	if err := os.MkdirAll(filepath.Join(dir, "Pictures"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Pictures", "a.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "styles.xml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, MimetypePath), []byte(testMimetype+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	packed, err := ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	changes, err := packed.FixManifest()
+	if err != nil {
+		t.Fatalf("FixManifest: %v", err)
+	}
+	wantChanges := []string{"removed manifest entry for missing styles.xml", "added manifest entry for Pictures/a.png"}
+	if strings.Join(changes, "\n") != strings.Join(wantChanges, "\n") {
+		t.Errorf("changes = %q, want %q", changes, wantChanges)
+	}
+
+	var buf bytes.Buffer
+	if err := packed.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := zr.File[0]; first.Name != MimetypePath || first.Method != zip.Store {
+		t.Errorf("first entry is %s with method %d, want a stored mimetype", first.Name, first.Method)
+	}
+	// The next entry's local header, starting "PK", follows the media type
+	if got := string(buf.Bytes()[38 : 38+len(testMimetype)+1]); got != testMimetype+"P" {
+		t.Errorf("mimetype isn't trimmed: %q", got)
+	}
+
+	methods := make(map[string]uint16)
+	for _, f := range zr.File {
+		methods[f.Name] = f.Method
+	}
+	want := map[string]uint16{
+		MimetypePath:               zip.Store,
+		ManifestPath:               zip.Deflate,
+		"content.xml":              zip.Deflate,
+		"Configurations2/toolbar/": zip.Store,
+		"Pictures/a.png":           zip.Store,
+	}
+	if len(methods) != len(want) {
+		t.Errorf("entries = %v, want %v", methods, want)
+	}
+	for name, method := range want {
+		if got, ok := methods[name]; !ok || got != method {
+			t.Errorf("%s method = %d (present %v), want %d", name, got, ok, method)
+		}
+	}
+
+	data, err := packed.ReadEntry(ManifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `manifest:full-path="Pictures/a.png"`) || strings.Contains(string(data), "styles.xml") {
+		t.Errorf("manifest not fixed:\n%s", data)
+	}
+}
@@ -0,0 +1,102 @@
+package odf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Predicate tests one aspect of the formatting of some text, such as
+// weight=bold or monospace
+type Predicate struct {
+	Property string
+	Value    string
+}
+
+// predicateValues lists the properties a predicate can test and the values
+// each accepts; color takes any #rrggbb value
+var predicateValues = map[string][]string{
+	"weight":    {"bold", "normal"},
+	"style":     {"italic", "normal"},
+	"position":  {"super", "sub", "normal"},
+	"monospace": {"yes", "no"},
+	"smallcaps": {"yes", "no"},
+	"underline": {"yes", "no"},
+	"strike":    {"yes", "no"},
+	"color":     nil,
+}
+
+// ParsePredicates reads space-separated predicates: property=value, or a
+// bare property name for a yes/no property that should hold
+func ParsePredicates(text string) ([]Predicate, error) {
+	var predicates []Predicate
+	for _, field := range strings.Fields(text) {
+		property, value, found := strings.Cut(field, "=")
+		property = strings.ToLower(property)
+		allowed, known := predicateValues[property]
+		if !known {
+			return nil, fmt.Errorf("unknown property '%s'", property)
+		}
+		if !found {
+			value = "yes"
+		}
+		value = strings.ToLower(value)
+		if property == "color" {
+			if !strings.HasPrefix(value, "#") || len(value) != 7 {
+				return nil, fmt.Errorf("color must be #rrggbb, got '%s'", value)
+			}
+		} else if !contains(allowed, value) {
+			return nil, fmt.Errorf("%s must be one of %s, got '%s'", property, strings.Join(allowed, ", "), value)
+		}
+		predicates = append(predicates, Predicate{Property: property, Value: value})
+	}
+	if len(predicates) == 0 {
+		return nil, fmt.Errorf("no predicates")
+	}
+	return predicates, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Holds reports whether formatting satisfies the predicate
+func (p Predicate) Holds(format TextFormat) bool {
+	switch p.Property {
+	case "weight":
+		return format.Bold == (p.Value == "bold")
+	case "style":
+		return format.Italic == (p.Value == "italic")
+	case "position":
+		return format.Position == p.Value || (format.Position == "" && p.Value == "normal")
+	case "monospace":
+		return format.Monospace == (p.Value == "yes")
+	case "smallcaps":
+		return format.SmallCaps == (p.Value == "yes")
+	case "underline":
+		return format.Underline == (p.Value == "yes")
+	case "strike":
+		return format.Strike == (p.Value == "yes")
+	case "color":
+		return strings.EqualFold(format.Color, p.Value)
+	}
+	return false
+}
+
+func (p Predicate) String() string {
+	return p.Property + "=" + p.Value
+}
+
+// AllHold reports whether formatting satisfies every predicate
+func AllHold(predicates []Predicate, format TextFormat) bool {
+	for _, p := range predicates {
+		if !p.Holds(format) {
+			return false
+		}
+	}
+	return true
+}
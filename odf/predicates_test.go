@@ -0,0 +1,112 @@
+package odf
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePredicates(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []Predicate
+		wantErr string
+	}{
+		{text: "weight=bold", want: []Predicate{{"weight", "bold"}}},
+		{text: "monospace", want: []Predicate{{"monospace", "yes"}}},
+		{text: " Style=Italic  position=super ", want: []Predicate{{"style", "italic"}, {"position", "super"}}},
+		{text: "color=#C9211E", want: []Predicate{{"color", "#c9211e"}}},
+		{text: "strike=no underline", want: []Predicate{{"strike", "no"}, {"underline", "yes"}}},
+		{text: "", wantErr: "no predicates"},
+		{text: "size=12pt", wantErr: "unknown property 'size'"},
+		{text: "weight=heavy", wantErr: "weight must be one of bold, normal"},
+		{text: "weight", wantErr: "weight must be one of"},
+		{text: "color=red", wantErr: "color must be #rrggbb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParsePredicates(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePredicates: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePredicates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllHold(t *testing.T) {
+	tests := []struct {
+		predicates string
+		format     TextFormat
+		want       bool
+	}{
+		{"weight=bold", TextFormat{Bold: true}, true},
+		{"weight=bold", TextFormat{Italic: true}, false},
+		{"weight=normal", TextFormat{}, true},
+		{"style=italic weight=bold", TextFormat{Bold: true, Italic: true}, true},
+		{"style=italic weight=bold", TextFormat{Italic: true}, false},
+		{"position=normal", TextFormat{}, true},
+		{"position=sub", TextFormat{Position: "sub"}, true},
+		{"position=normal", TextFormat{Position: "super"}, false},
+		{"monospace", TextFormat{Monospace: true}, true},
+		{"monospace=no", TextFormat{Monospace: true}, false},
+		{"smallcaps underline strike", TextFormat{SmallCaps: true, Underline: true, Strike: true}, true},
+		{"color=#C9211E", TextFormat{Color: "#c9211e"}, true},
+		{"color=#c9211e", TextFormat{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.predicates, func(t *testing.T) {
+			predicates, err := ParsePredicates(tt.predicates)
+			if err != nil {
+				t.Fatalf("ParsePredicates: %v", err)
+			}
+			if got := AllHold(predicates, tt.format); got != tt.want {
+				t.Errorf("AllHold(%v, %+v) = %v, want %v", predicates, tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQName(t *testing.T) {
+	tests := []struct {
+		qname   string
+		want    xml.Name
+		wantErr string
+	}{
+		{qname: "text:list-item", want: xml.Name{Space: NSText, Local: "list-item"}},
+		{qname: "table:table-cell", want: xml.Name{Space: NSTable, Local: "table-cell"}},
+		{qname: "draw:frame", want: xml.Name{Space: NSDraw, Local: "frame"}},
+		{qname: "list-item", wantErr: "not a prefixed name"},
+		{qname: "text:", wantErr: "not a prefixed name"},
+		{qname: "tb:table", wantErr: "unknown namespace prefix 'tb'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.qname, func(t *testing.T) {
+			got, err := ParseQName(tt.qname)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQName: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseQName = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package odf
+
+import (
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// LibreOffice tags text with the id of the editing session that typed it
+// (officeooo:rsid, officeooo:paragraph-rsid). Each id needs its own
+// automatic style, so a paragraph typed over several sessions is cut into
+// spans that look the same but have different styles. Stripping the ids
+// first leaves the spans that carry real formatting for the other
+// transforms to work on.
+
+// RsidResult counts what StripRsids removed
+type RsidResult struct {
+	Properties int         // rsid attributes removed from style properties
+	Styles     []StyleNode // automatic styles left with nothing to say
+	Spans      int         // spans unwrapped because they no longer format their text
+}
+
+// StripRsids removes the rsid properties from every style of the main
+// document (prefix "") or of an embedded sub-document. Automatic styles
+// left empty are deleted, references to them point at their parent style
+// instead, and spans that end up with no style are replaced by their text.
+func (d *Document) StripRsids(prefix string) (*RsidResult, error) {
+	result := &RsidResult{}
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		part, err := d.Part(prefix + name)
+		if err != nil {
+			return nil, err
+		}
+		if part == nil {
+			continue
+		}
+		for _, local := range []string{"styles", "automatic-styles"} {
+			if section := part.Root.Child(NSOffice, local); section != nil {
+				result.Properties += stripRsidProperties(section)
+			}
+		}
+
+		emptied := emptyAutomaticStyles(part.Root)
+		for key, definition := range emptied {
+			definition.Remove()
+			result.Styles = append(result.Styles, StyleNode{Part: prefix + name, Key: key})
+		}
+		result.Spans += replaceEmptyStyles(part.Root, emptied)
+		part.Root.Normalize()
+	}
+	return result, nil
+}
+
+// stripRsidProperties removes the rsid attributes from the property
+// elements below n, and the property elements left with nothing in them
+func stripRsidProperties(n *xmltree.Node) int {
+	removed := 0
+	var empty []*xmltree.Node
+	n.Walk(func(c *xmltree.Node) bool {
+		if c.Kind != xmltree.ElementNode {
+			return false
+		}
+		if c.Name.Space != NSStyle || !isPropertiesElement(c.Name.Local) {
+			return true
+		}
+		attrs := c.Attrs[:0]
+		for _, attr := range c.Attrs {
+			if IsRsid(attr.Name) {
+				removed++
+				continue
+			}
+			attrs = append(attrs, attr)
+		}
+		c.Attrs = attrs
+		if len(c.Attrs) == 0 && len(c.Children) == 0 {
+			empty = append(empty, c)
+		}
+		return false
+	})
+	for _, c := range empty {
+		c.Remove()
+	}
+	return removed
+}
+
+// emptyAutomaticStyles finds the automatic styles of a part that neither
+// set a property nor do anything but name their parent. Only paragraph and
+// text styles are taken without a parent, as text with no style of those
+// families simply has the default formatting.
+func emptyAutomaticStyles(root *xmltree.Node) map[StyleKey]*xmltree.Node {
+	emptied := make(map[StyleKey]*xmltree.Node)
+	section := root.Child(NSOffice, "automatic-styles")
+	if section == nil {
+		return emptied
+	}
+	for _, n := range section.Elements() {
+		if !n.Is(NSStyle, "style") || len(n.Children) > 0 {
+			continue
+		}
+		onlyNames := true
+		for _, attr := range n.Attrs {
+			if attr.Name.Space != NSStyle || (attr.Name.Local != "name" && attr.Name.Local != "family" && attr.Name.Local != "parent-style-name") {
+				onlyNames = false
+			}
+		}
+		key := StyleKey{Family: n.AttrValue(NSStyle, "family"), Name: n.AttrValue(NSStyle, "name")}
+		parent := n.AttrValue(NSStyle, "parent-style-name")
+		if onlyNames && (parent != "" || key.Family == "paragraph" || key.Family == "text") {
+			emptied[key] = n
+		}
+	}
+	return emptied
+}
+
+// replaceEmptyStyles points references to the emptied styles at their
+// parents, or drops them if they have none, then unwraps the spans left
+// with no attributes at all. It returns the number of spans unwrapped.
+func replaceEmptyStyles(root *xmltree.Node, emptied map[StyleKey]*xmltree.Node) int {
+	var spans []*xmltree.Node
+	root.Walk(func(n *xmltree.Node) bool {
+		if n.Kind != xmltree.ElementNode {
+			return false
+		}
+		attrs := n.Attrs[:0]
+		for _, attr := range n.Attrs {
+			ref, ok := LookupStyleRef(n, attr.Name)
+			if ok && !ref.Defines {
+				var names []string
+				for _, name := range ref.StyleNames(attr.Value) {
+					if definition, ok := emptied[StyleKey{Family: ref.Family, Name: name}]; ok {
+						name = definition.AttrValue(NSStyle, "parent-style-name")
+					}
+					if name != "" {
+						names = append(names, name)
+					}
+				}
+				if len(names) == 0 {
+					continue
+				}
+				attr.Value = strings.Join(names, " ")
+			}
+			attrs = append(attrs, attr)
+		}
+		n.Attrs = attrs
+		if n.Is(NSText, "span") && len(n.Attrs) == 0 {
+			spans = append(spans, n)
+		}
+		return true
+	})
+	for _, span := range spans {
+		span.Unwrap()
+	}
+	return len(spans)
+}
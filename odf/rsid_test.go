@@ -0,0 +1,113 @@
+package odf
+
+import (
+	"strings"
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestStripRsids(t *testing.T) {
+	tests := []struct {
+		name       string
+		styles     string // automatic styles of content.xml
+		text       string // office:text of content.xml
+		wantStyles string
+		wantText   string
+		want       RsidResult
+	}{
+		{
+			name:       "span with only an rsid is unwrapped",
+			styles:     `<style:style style:name="T1" style:family="text"><style:text-properties officeooo:rsid="0012ab"/></style:style>`,
+			text:       `<text:p>one <text:span text:style-name="T1">two</text:span> three</text:p>`,
+			wantStyles: ``,
+			wantText:   `<text:p>one two three</text:p>`,
+			want:       RsidResult{Properties: 1, Styles: []StyleNode{{Part: "content.xml", Key: StyleKey{Family: "text", Name: "T1"}}}, Spans: 1},
+		},
+		{
+			name:       "span with real formatting keeps its style",
+			styles:     `<style:style style:name="T1" style:family="text"><style:text-properties fo:font-weight="bold" officeooo:rsid="0012ab"/></style:style>`,
+			text:       `<text:p>one <text:span text:style-name="T1">two</text:span></text:p>`,
+			wantStyles: `<style:style style:name="T1" style:family="text"><style:text-properties fo:font-weight="bold"/></style:style>`,
+			wantText:   `<text:p>one <text:span text:style-name="T1">two</text:span></text:p>`,
+			want:       RsidResult{Properties: 1},
+		},
+		{
+			name:       "paragraph style falls back to its parent",
+			styles:     `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Text_20_body"><style:text-properties officeooo:paragraph-rsid="00aa" officeooo:rsid="00bb"/></style:style>`,
+			text:       `<text:p text:style-name="P1">x</text:p>`,
+			wantStyles: ``,
+			wantText:   `<text:p text:style-name="Text_20_body">x</text:p>`,
+			want:       RsidResult{Properties: 2, Styles: []StyleNode{{Part: "content.xml", Key: StyleKey{Family: "paragraph", Name: "P1"}}}},
+		},
+		{
+			name:       "paragraph style with no parent is dropped",
+			styles:     `<style:style style:name="P1" style:family="paragraph"><style:paragraph-properties officeooo:paragraph-rsid="00aa"/></style:style>`,
+			text:       `<text:p text:style-name="P1">x</text:p>`,
+			wantStyles: ``,
+			wantText:   `<text:p>x</text:p>`,
+			want:       RsidResult{Properties: 1, Styles: []StyleNode{{Part: "content.xml", Key: StyleKey{Family: "paragraph", Name: "P1"}}}},
+		},
+		{
+			name:       "style with other attributes is kept",
+			styles:     `<style:style style:name="P1" style:family="paragraph" style:master-page-name="First"><style:paragraph-properties officeooo:paragraph-rsid="00aa"/></style:style>`,
+			text:       `<text:p text:style-name="P1">x</text:p>`,
+			wantStyles: `<style:style style:name="P1" style:family="paragraph" style:master-page-name="First"/>`,
+			wantText:   `<text:p text:style-name="P1">x</text:p>`,
+			want:       RsidResult{Properties: 1},
+		},
+		{
+			name:       "span with other attributes is kept",
+			styles:     `<style:style style:name="T1" style:family="text"><style:text-properties officeooo:rsid="0012ab"/></style:style>`,
+			text:       `<text:p><text:span text:style-name="T1" text:class-names="Note">x</text:span></text:p>`,
+			wantStyles: ``,
+			wantText:   `<text:p><text:span text:class-names="Note">x</text:span></text:p>`,
+			want:       RsidResult{Properties: 1, Styles: []StyleNode{{Part: "content.xml", Key: StyleKey{Family: "text", Name: "T1"}}}},
+		},
+		{
+			name:       "nothing to strip",
+			styles:     `<style:style style:name="T1" style:family="text"><style:text-properties fo:font-style="italic"/></style:style>`,
+			text:       `<text:p><text:span text:style-name="T1">x</text:span></text:p>`,
+			wantStyles: `<style:style style:name="T1" style:family="text"><style:text-properties fo:font-style="italic"/></style:style>`,
+			wantText:   `<text:p><text:span text:style-name="T1">x</text:span></text:p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := parseTestPart(t, `<office:automatic-styles>`+tt.styles+`</office:automatic-styles>`+
+				`<office:body><office:text>`+tt.text+`</office:text></office:body>`)
+			doc := &Document{Package: New(), parts: map[string]*xmltree.Document{"content.xml": content}}
+
+			result, err := doc.StripRsids("")
+			if err != nil {
+				t.Fatalf("StripRsids: %v", err)
+			}
+			if result.Properties != tt.want.Properties || result.Spans != tt.want.Spans || len(result.Styles) != len(tt.want.Styles) {
+				t.Errorf("result = %+v, want %+v", *result, tt.want)
+			}
+			for i := range tt.want.Styles {
+				if i < len(result.Styles) && result.Styles[i] != tt.want.Styles[i] {
+					t.Errorf("removed style %v, want %v", result.Styles[i], tt.want.Styles[i])
+				}
+			}
+
+			if got := innerXML(content.Root.Child(NSOffice, "automatic-styles")); got != tt.wantStyles {
+				t.Errorf("automatic styles = %s\nwant %s", got, tt.wantStyles)
+			}
+			text := content.Root.Child(NSOffice, "body").Child(NSOffice, "text")
+			if got := innerXML(text); got != tt.wantText {
+				t.Errorf("text = %s\nwant %s", got, tt.wantText)
+			}
+		})
+	}
+}
+
+// innerXML serialises the children of n
+func innerXML(n *xmltree.Node) string {
+	var b strings.Builder
+	for _, c := range n.Children {
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package odf
+
+import "LibreOfficeReformatter/xmltree"
+
+// MergeSpans joins adjacent spans below n that have the same attributes,
+// such as two neighbouring spans that both became Italic
+func MergeSpans(n *xmltree.Node) {
+	for i := 0; i < len(n.Children); i++ {
+		span := n.Children[i]
+		if span.Kind != xmltree.ElementNode {
+			continue
+		}
+		for i+1 < len(n.Children) && span.Is(NSText, "span") && sameAttrs(span, n.Children[i+1]) {
+			next := n.Children[i+1]
+			for _, c := range next.Children {
+				span.AppendChild(c)
+			}
+			next.Remove()
+		}
+		MergeSpans(span)
+	}
+}
+
+// sameAttrs reports whether b is an element of the same name as a with the
+// same attributes
+func sameAttrs(a, b *xmltree.Node) bool {
+	if !b.Is(a.Name.Space, a.Name.Local) || len(a.Attrs) != len(b.Attrs) {
+		return false
+	}
+	for _, attr := range a.Attrs {
+		if value, ok := b.Attr(attr.Name.Space, attr.Name.Local); !ok || value != attr.Value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package odf
+
+import "testing"
+
+func TestMergeSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"same style",
+			`<text:span text:style-name="Italic">I</text:span><text:span text:style-name="Italic">n</text:span> x`,
+			`<text:span text:style-name="Italic">In</text:span> x`,
+		},
+		{
+			"different styles",
+			`<text:span text:style-name="Italic">I</text:span><text:span text:style-name="Bold">n</text:span>`,
+			`<text:span text:style-name="Italic">I</text:span><text:span text:style-name="Bold">n</text:span>`,
+		},
+		{
+			"text between",
+			`<text:span text:style-name="Italic">a</text:span> <text:span text:style-name="Italic">b</text:span>`,
+			`<text:span text:style-name="Italic">a</text:span> <text:span text:style-name="Italic">b</text:span>`,
+		},
+		{
+			"extra attribute",
+			`<text:span text:style-name="Italic">a</text:span><text:span text:style-name="Italic" text:class-names="x">b</text:span>`,
+			`<text:span text:style-name="Italic">a</text:span><text:span text:style-name="Italic" text:class-names="x">b</text:span>`,
+		},
+		{
+			"three in a row, and spans inside",
+			`<text:span text:style-name="T1"><text:span text:style-name="Italic">a</text:span></text:span>` +
+				`<text:span text:style-name="T1"><text:span text:style-name="Italic">b</text:span></text:span>` +
+				`<text:span text:style-name="T1">c</text:span>`,
+			`<text:span text:style-name="T1"><text:span text:style-name="Italic">ab</text:span>c</text:span>`,
+		},
+		{
+			"other elements are left alone",
+			`<text:a xlink:href="#a">a</text:a><text:a xlink:href="#a">b</text:a>`,
+			`<text:a xlink:href="#a">a</text:a><text:a xlink:href="#a">b</text:a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := parseTestPart(t, `<text:p xmlns:xlink="http://www.w3.org/1999/xlink">`+tt.in+`</text:p>`)
+			paragraph := findElement(content.Root, "text:p")
+			MergeSpans(paragraph)
+			paragraph.Normalize()
+			if got := innerXML(paragraph); got != tt.want {
+				t.Errorf("MergeSpans = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
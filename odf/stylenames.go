@@ -0,0 +1,79 @@
+package odf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Style names are XML NCNames, so LibreOffice escapes every character that
+// isn't allowed in one as _xx_, the character's code in lower-case hex:
+// "Preformatted Text" is stored as Preformatted_20_Text, and an underscore
+// as _5f_. The display name keeps the readable form.
+
+// nameChar reports whether LibreOffice keeps r as-is at position i of a name
+func nameChar(r rune, i int) bool {
+	switch {
+	case r == '_' || r == ':':
+		return false
+	case r < 0x80:
+		return unicode.IsLetter(r) || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'))
+	case unicode.IsLetter(r):
+		return true
+	default:
+		return i > 0 && (unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Lm) || r == 0xb7)
+	}
+}
+
+// EncodeStyleName turns a display name into the internal style name
+func EncodeStyleName(display string) string {
+	var b strings.Builder
+	for i, r := range []rune(display) {
+		if nameChar(r, i) {
+			b.WriteRune(r)
+			continue
+		}
+		fmt.Fprintf(&b, "_%x_", r)
+	}
+	return b.String()
+}
+
+// DecodeStyleName turns an internal style name back into its display form
+func DecodeStyleName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '_' {
+			if end := strings.IndexByte(name[i+1:], '_'); end > 0 && end <= 6 {
+				if code, err := strconv.ParseUint(name[i+1:i+1+end], 16, 32); err == nil {
+					b.WriteRune(rune(code))
+					i += end + 1
+					continue
+				}
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// NeedsEncoding reports whether s can only be a display name, because it
+// holds characters other than underscores that a style name can't. Names
+// like Preformatted_20_Text or HeadA are taken as internal names.
+func NeedsEncoding(s string) bool {
+	for i, r := range []rune(s) {
+		if r != '_' && !nameChar(r, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// DisplayNameFor derives the display name LibreOffice would show for an
+// internal name, or "" when it would show the name itself
+func DisplayNameFor(name string) string {
+	if display := DecodeStyleName(name); display != name {
+		return display
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package odf
+
+import "testing"
+
+func TestStyleNameEncoding(t *testing.T) {
+	tests := []struct {
+		display  string
+		internal string
+	}{
+		{"Text body", "Text_20_body"},
+		{"Preformatted Text", "Preformatted_20_Text"},
+		{"HeadA", "HeadA"},
+		{"Heading 1", "Heading_20_1"},
+		{"snake_case", "snake_5f_case"},
+		{"1st Level", "_31_st_20_Level"},
+		{"Level-2.b", "Level-2.b"},
+		{"-x", "_2d_x"},
+		{"Zitat (kursiv)", "Zitat_20__28_kursiv_29_"},
+		{"Überschrift", "Überschrift"},
+		{"a:b", "a_3a_b"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.display, func(t *testing.T) {
+			if got := EncodeStyleName(tt.display); got != tt.internal {
+				t.Errorf("EncodeStyleName(%q) = %q, want %q", tt.display, got, tt.internal)
+			}
+			if got := DecodeStyleName(tt.internal); got != tt.display {
+				t.Errorf("DecodeStyleName(%q) = %q, want %q", tt.internal, got, tt.display)
+			}
+		})
+	}
+}
+
+func TestDecodeStyleNameLeavesOtherUnderscores(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"My_Style", "My_Style"},
+		{"a_zz_b", "a_zz_b"},
+		{"trailing_", "trailing_"},
+		{"a__b", "a__b"},
+		{"_1234567_", "_1234567_"},
+	}
+
+	for _, tt := range tests {
+		if got := DecodeStyleName(tt.name); got != tt.want {
+			t.Errorf("DecodeStyleName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"Text body", true},
+		{"Preformatted_20_Text", false},
+		{"HeadA", false},
+		{"My_Style", false},
+		{"1st", true},
+		{"Zitat (kursiv)", true},
+	}
+
+	for _, tt := range tests {
+		if got := NeedsEncoding(tt.name); got != tt.want {
+			t.Errorf("NeedsEncoding(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayNameFor(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Text_20_body", "Text body"},
+		{"HeadA", ""},
+		{"My_Style", ""},
+	}
+
+	for _, tt := range tests {
+		if got := DisplayNameFor(tt.name); got != tt.want {
+			t.Errorf("DisplayNameFor(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       string
+	}{
+		{`<style:style style:name="Text_20_body" style:display-name="Text body" style:family="paragraph"/>`, "Text body"},
+		{`<text:list-style style:name="List_20_1" style:display-name="List 1"/>`, "List 1"},
+		{`<text:list-style style:name="L1" text:display-name="Wrong"/>`, ""},
+		{`<text:outline-style style:name="Outline" style:display-name="Chapters"/>`, "Chapters"},
+		{`<number:date-style style:name="N37" style:display-name="Long Date"/>`, "Long Date"},
+		{`<draw:gradient draw:name="Sunrise_20_Sky" draw:display-name="Sunrise Sky"/>`, "Sunrise Sky"},
+		{`<draw:gradient draw:name="G1" style:display-name="Wrong"/>`, ""},
+		{`<style:style style:name="HeadA" style:family="paragraph"/>`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			part := parseTestPart(t, tt.definition)
+			if got := DisplayName(part.Root.Elements()[0]); got != tt.want {
+				t.Errorf("DisplayName(%s) = %q, want %q", tt.definition, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package odf
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// StyleRef describes an attribute that holds a style name: which family the
+// name belongs to, whether the attribute defines the style rather than
+// referring to one, and whether it holds a space-separated list of names
+type StyleRef struct {
+	Family   string
+	Defines  bool
+	Multiple bool
+}
+
+// styleAttr is one registry entry. A zero element matches the attribute on
+// any element; an empty family means the family of the enclosing style
+// definition, as for style:parent-style-name.
+type styleAttr struct {
+	attr     xml.Name
+	element  xml.Name
+	family   string
+	defines  bool
+	multiple bool
+}
+
+func qname(space, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+// styleAttrs is the registry of every ODF 1.3/1.4 attribute that names a
+// style, plus the LibreOffice extensions seen in practice. Anything that
+// renames, checks or reports on styles goes through it.
+var styleAttrs = []styleAttr{
+	// Definitions
+	{attr: qname(NSStyle, "name"), element: qname(NSStyle, "style"), defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSText, "list-style"), family: "list", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSText, "outline-style"), family: "list", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSStyle, "master-page"), family: "master-page", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSStyle, "page-layout"), family: "page-layout", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSStyle, "presentation-page-layout"), family: "presentation-page-layout", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSStyle, "font-face"), family: "font-face", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSNumber, "number-style"), family: "data", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSNumber, "currency-style"), family: "data", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSNumber, "percentage-style"), family: "data", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSNumber, "date-style"), family: "data", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSNumber, "time-style"), family: "data", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSNumber, "boolean-style"), family: "data", defines: true},
+	{attr: qname(NSStyle, "name"), element: qname(NSNumber, "text-style"), family: "data", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSDraw, "gradient"), family: "gradient", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSSvg, "linearGradient"), family: "gradient", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSSvg, "radialGradient"), family: "gradient", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSDraw, "hatch"), family: "hatch", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSDraw, "fill-image"), family: "fill-image", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSDraw, "marker"), family: "marker", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSDraw, "stroke-dash"), family: "stroke-dash", defines: true},
+	{attr: qname(NSDraw, "name"), element: qname(NSDraw, "opacity"), family: "opacity", defines: true},
+	{attr: qname(NSTable, "name"), element: qname(NSTable, "table-template"), family: "table-template", defines: true},
+	{attr: qname(NSText, "style-name"), element: qname(NSTable, "table-template"), family: "table-template", defines: true},
+
+	// References between styles
+	{attr: qname(NSStyle, "parent-style-name")},
+	{attr: qname(NSStyle, "next-style-name"), family: "paragraph"},
+	{attr: qname(NSStyle, "apply-style-name"), element: qname(NSStyle, "map")},
+	{attr: qname(NSStyle, "list-style-name"), family: "list"},
+	{attr: qname(NSStyle, "master-page-name"), family: "master-page"},
+	{attr: qname(NSStyle, "page-layout-name"), family: "page-layout"},
+	{attr: qname(NSStyle, "data-style-name"), family: "data"},
+	{attr: qname(NSStyle, "percentage-data-style-name"), family: "data"},
+	{attr: qname(NSStyle, "register-truth-ref-style-name"), family: "paragraph"},
+	{attr: qname(NSStyle, "leader-text-style"), family: "text"},
+	{attr: qname(NSStyle, "text-line-through-text-style"), family: "text"},
+	{attr: qname(NSStyle, "font-name"), family: "font-face"},
+	{attr: qname(NSStyle, "font-name-asian"), family: "font-face"},
+	{attr: qname(NSStyle, "font-name-complex"), family: "font-face"},
+
+	// Paragraph and character content
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "p"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "h"), family: "paragraph"},
+	{attr: qname(NSText, "cond-style-name"), element: qname(NSText, "p"), family: "paragraph"},
+	{attr: qname(NSText, "cond-style-name"), element: qname(NSText, "h"), family: "paragraph"},
+	{attr: qname(NSText, "class-names"), element: qname(NSText, "p"), family: "paragraph", multiple: true},
+	{attr: qname(NSText, "class-names"), element: qname(NSText, "h"), family: "paragraph", multiple: true},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "span"), family: "text"},
+	{attr: qname(NSText, "class-names"), element: qname(NSText, "span"), family: "text", multiple: true},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "a"), family: "text"},
+	{attr: qname(NSText, "visited-style-name"), element: qname(NSText, "a"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "ruby"), family: "ruby"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "ruby-text"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "section"), family: "section"},
+
+	// Lists and outline numbering
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "list"), family: "list"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "numbered-paragraph"), family: "list"},
+	{attr: qname(NSText, "style-override"), element: qname(NSText, "list-item"), family: "list"},
+	{attr: qname(NSText, "style-override"), element: qname(NSText, "list-header"), family: "list"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "list-level-style-number"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "list-level-style-bullet"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "outline-level-style"), family: "text"},
+
+	// Notes and line numbering configuration
+	{attr: qname(NSText, "citation-style-name"), element: qname(NSText, "notes-configuration"), family: "text"},
+	{attr: qname(NSText, "citation-body-style-name"), element: qname(NSText, "notes-configuration"), family: "text"},
+	{attr: qname(NSText, "default-style-name"), element: qname(NSText, "notes-configuration"), family: "paragraph"},
+	{attr: qname(NSText, "master-page-name"), element: qname(NSText, "notes-configuration"), family: "master-page"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "linenumbering-configuration"), family: "text"},
+
+	// Indexes: the sections holding them, their templates and source styles
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "table-of-content"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "illustration-index"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "table-index"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "object-index"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "user-index"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "alphabetical-index"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "bibliography"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-title"), family: "section"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-title-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "table-of-content-entry-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "illustration-index-entry-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "table-index-entry-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "object-index-entry-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "user-index-entry-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "alphabetical-index-entry-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "bibliography-entry-template"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-source-style"), family: "paragraph"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-entry-chapter"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-entry-page-number"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-entry-text"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-entry-span"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-entry-tab-stop"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-entry-link-start"), family: "text"},
+	{attr: qname(NSText, "style-name"), element: qname(NSText, "index-entry-bibliography"), family: "text"},
+	{attr: qname(NSText, "main-entry-style-name"), element: qname(NSText, "alphabetical-index-source"), family: "text"},
+
+	// Tables
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "table"), family: "table"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "table-column"), family: "table-column"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "table-row"), family: "table-row"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "table-cell"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "covered-table-cell"), family: "table-cell"},
+	{attr: qname(NSTable, "default-cell-style-name"), element: qname(NSTable, "table-column"), family: "table-cell"},
+	{attr: qname(NSTable, "default-cell-style-name"), element: qname(NSTable, "table-row"), family: "table-cell"},
+	{attr: qname(NSTable, "template-name"), element: qname(NSTable, "table"), family: "table-template"},
+
+	// Table templates: the cell and paragraph styles of each table region
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "first-row"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "last-row"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "first-column"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "last-column"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "body"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "even-rows"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "odd-rows"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "even-columns"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "odd-columns"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSTable, "background"), family: "table-cell"},
+	{attr: qname(NSTable, "paragraph-style-name"), family: "paragraph"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSLoext, "first-row-even-column"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSLoext, "last-row-even-column"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSLoext, "first-row-end-column"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSLoext, "first-row-start-column"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSLoext, "last-row-end-column"), family: "table-cell"},
+	{attr: qname(NSTable, "style-name"), element: qname(NSLoext, "last-row-start-column"), family: "table-cell"},
+
+	// Drawing objects, their fills and strokes, and pages
+	{attr: qname(NSDraw, "style-name"), family: "graphic"},
+	{attr: qname(NSDraw, "style-name"), element: qname(NSDraw, "page"), family: "drawing-page"},
+	{attr: qname(NSDraw, "style-name"), element: qname(NSStyle, "master-page"), family: "drawing-page"},
+	{attr: qname(NSDraw, "class-names"), family: "graphic", multiple: true},
+	{attr: qname(NSDraw, "text-style-name"), family: "paragraph"},
+	{attr: qname(NSDraw, "master-page-name"), family: "master-page"},
+	{attr: qname(NSDraw, "fill-gradient-name"), family: "gradient"},
+	{attr: qname(NSDraw, "fill-hatch-name"), family: "hatch"},
+	{attr: qname(NSDraw, "fill-image-name"), family: "fill-image"},
+	{attr: qname(NSDraw, "opacity-name"), family: "opacity"},
+	{attr: qname(NSDraw, "stroke-dash"), family: "stroke-dash"},
+	{attr: qname(NSDraw, "stroke-dash-names"), family: "stroke-dash", multiple: true},
+	{attr: qname(NSDraw, "marker-start"), family: "marker"},
+	{attr: qname(NSDraw, "marker-end"), family: "marker"},
+	{attr: qname(NSPresent, "style-name"), family: "presentation"},
+	{attr: qname(NSPresent, "class-names"), family: "presentation", multiple: true},
+	{attr: qname(NSPresent, "presentation-page-layout-name"), family: "presentation-page-layout"},
+	{attr: qname(NSChart, "style-name"), family: "chart"},
+}
+
+// styleAttrIndex groups the registry by attribute name
+var styleAttrIndex = func() map[xml.Name][]styleAttr {
+	index := make(map[xml.Name][]styleAttr)
+	for _, sa := range styleAttrs {
+		index[sa.attr] = append(index[sa.attr], sa)
+	}
+	return index
+}()
+
+// LookupStyleRef reports whether the attribute attr on element holds a style
+// name, and which family of style it names
+func LookupStyleRef(element *xmltree.Node, attr xml.Name) (StyleRef, bool) {
+	var match *styleAttr
+	for i, sa := range styleAttrIndex[attr] {
+		if sa.element == element.Name {
+			match = &styleAttrIndex[attr][i]
+			break
+		}
+		if sa.element.Local == "" && match == nil {
+			match = &styleAttrIndex[attr][i]
+		}
+	}
+	if match == nil {
+		return StyleRef{}, false
+	}
+
+	ref := StyleRef{Family: match.family, Defines: match.defines, Multiple: match.multiple}
+	if ref.Family == "" {
+		ref.Family = DefinitionFamily(element)
+	}
+	return ref, ref.Family != ""
+}
+
+// DefinitionFamily returns the family of the style definition that element
+// is, or is inside, or "" if it isn't part of one
+func DefinitionFamily(element *xmltree.Node) string {
+	for n := element; n != nil; n = n.Parent {
+		switch {
+		case n.Is(NSStyle, "style"), n.Is(NSStyle, "default-style"):
+			return n.AttrValue(NSStyle, "family")
+		case n.Is(NSText, "list-style"), n.Is(NSText, "outline-style"):
+			return "list"
+		case n.Kind == xmltree.ElementNode && n.Name.Space == NSNumber && strings.HasSuffix(n.Name.Local, "-style"):
+			return "data"
+		}
+	}
+	return ""
+}
+
+// StyleNames splits an attribute value into the style names it holds
+func (ref StyleRef) StyleNames(value string) []string {
+	if ref.Multiple {
+		return strings.Fields(value)
+	}
+	return []string{value}
+}
+
+// KnownFamily reports whether family is one the registry knows about
+func KnownFamily(family string) bool {
+	for _, sa := range styleAttrs {
+		if sa.family == family {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package odf
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// testNamespaces declares the usual prefixes, for building test parts
+const testNamespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+	` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+	` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+	` xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"` +
+	` xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"` +
+	` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"` +
+	` xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"` +
+	` xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0"` +
+	` xmlns:loext="urn:org:documentfoundation:names:experimental:office:xmlns:loext:1.0"` +
+	` xmlns:officeooo="http://openoffice.org/2009/office"`
+
+// parseTestPart parses the body of an office:document-content element
+func parseTestPart(t *testing.T, body string) *xmltree.Document {
+	t.Helper()
+	doc, err := xmltree.Parse([]byte(`<office:document-content ` + testNamespaces + `>` + body + `</office:document-content>`))
+	if err != nil {
+		t.Fatalf("parsing test part: %v", err)
+	}
+	return doc
+}
+
+// findElement returns the first element with the given prefixed name
+func findElement(root *xmltree.Node, qname string) *xmltree.Node {
+	var found *xmltree.Node
+	root.Walk(func(n *xmltree.Node) bool {
+		if found == nil && n.Kind == xmltree.ElementNode && n.QName() == qname {
+			found = n
+		}
+		return found == nil
+	})
+	return found
+}
+
+func TestLookupStyleRef(t *testing.T) {
+	doc := parseTestPart(t, `<office:styles>`+
+		`<style:style style:name="Quote" style:family="paragraph" style:parent-style-name="Standard" style:next-style-name="Body">`+
+		`<style:text-properties style:font-name="Courier"/></style:style>`+
+		`<style:style style:name="T" style:family="text" style:parent-style-name="Emphasis"/>`+
+		`<text:list-style style:name="L1"><text:list-level-style-bullet text:style-name="Bullets"/></text:list-style>`+
+		`<number:date-style style:name="N1"/>`+
+		`<table:table-template table:name="Blue" text:style-name="Blue"><table:first-row table:style-name="BlueHead" table:paragraph-style-name="HeadText"/></table:table-template>`+
+		`<loext:table-template><loext:first-row-even-column table:style-name="Cell"/></loext:table-template>`+
+		`</office:styles><office:body><office:text>`+
+		`<text:p text:style-name="P1" text:class-names="A B"><text:span text:style-name="T1">x</text:span><text:a text:style-name="Link" text:visited-style-name="Visited">y</text:a></text:p>`+
+		`<text:list text:style-name="L1"/>`+
+		`<table:table table:style-name="Table1" table:template-name="Blue"><table:table-cell table:style-name="A1"/></table:table>`+
+		`<draw:frame draw:style-name="fr1" draw:text-style-name="P2"/>`+
+		`</office:text></office:body>`)
+
+	tests := []struct {
+		element string
+		attr    xml.Name
+		want    StyleRef
+		ok      bool
+	}{
+		{"style:style", qname(NSStyle, "name"), StyleRef{Family: "paragraph", Defines: true}, true},
+		{"style:style", qname(NSStyle, "parent-style-name"), StyleRef{Family: "paragraph"}, true},
+		{"style:style", qname(NSStyle, "next-style-name"), StyleRef{Family: "paragraph"}, true},
+		{"style:style", qname(NSStyle, "family"), StyleRef{}, false},
+		{"style:text-properties", qname(NSStyle, "font-name"), StyleRef{Family: "font-face"}, true},
+		{"text:list-style", qname(NSStyle, "name"), StyleRef{Family: "list", Defines: true}, true},
+		{"text:list-level-style-bullet", qname(NSText, "style-name"), StyleRef{Family: "text"}, true},
+		{"number:date-style", qname(NSStyle, "name"), StyleRef{Family: "data", Defines: true}, true},
+		{"table:table-template", qname(NSTable, "name"), StyleRef{Family: "table-template", Defines: true}, true},
+		{"table:table-template", qname(NSText, "style-name"), StyleRef{Family: "table-template", Defines: true}, true},
+		{"table:first-row", qname(NSTable, "style-name"), StyleRef{Family: "table-cell"}, true},
+		{"table:first-row", qname(NSTable, "paragraph-style-name"), StyleRef{Family: "paragraph"}, true},
+		{"loext:first-row-even-column", qname(NSTable, "style-name"), StyleRef{Family: "table-cell"}, true},
+		{"text:p", qname(NSText, "style-name"), StyleRef{Family: "paragraph"}, true},
+		{"text:p", qname(NSText, "class-names"), StyleRef{Family: "paragraph", Multiple: true}, true},
+		{"text:span", qname(NSText, "style-name"), StyleRef{Family: "text"}, true},
+		{"text:a", qname(NSText, "visited-style-name"), StyleRef{Family: "text"}, true},
+		{"text:list", qname(NSText, "style-name"), StyleRef{Family: "list"}, true},
+		{"table:table", qname(NSTable, "style-name"), StyleRef{Family: "table"}, true},
+		{"table:table", qname(NSTable, "template-name"), StyleRef{Family: "table-template"}, true},
+		{"table:table-cell", qname(NSTable, "style-name"), StyleRef{Family: "table-cell"}, true},
+		{"draw:frame", qname(NSDraw, "style-name"), StyleRef{Family: "graphic"}, true},
+		{"draw:frame", qname(NSDraw, "text-style-name"), StyleRef{Family: "paragraph"}, true},
+		{"draw:frame", qname(NSDraw, "name"), StyleRef{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.element+"/"+tt.attr.Local, func(t *testing.T) {
+			element := findElement(doc.Root, tt.element)
+			if element == nil {
+				t.Fatalf("no %s in the test part", tt.element)
+			}
+			got, ok := LookupStyleRef(element, tt.attr)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("LookupStyleRef = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestStyleNames(t *testing.T) {
+	tests := []struct {
+		ref   StyleRef
+		value string
+		want  []string
+	}{
+		{StyleRef{Family: "paragraph"}, "Text body", []string{"Text body"}},
+		{StyleRef{Family: "paragraph", Multiple: true}, "A  B C", []string{"A", "B", "C"}},
+		{StyleRef{Family: "graphic", Multiple: true}, "", []string{}},
+	}
+	for _, tt := range tests {
+		if got := tt.ref.StyleNames(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StyleNames(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
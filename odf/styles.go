@@ -0,0 +1,245 @@
+package odf
+
+import (
+	"encoding/xml"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// StyleDef is a style:style definition, either a common style from
+// office:styles or an automatic style (P1, T7...) that LibreOffice writes
+// for direct formatting
+type StyleDef struct {
+	Key       StyleKey
+	Part      string
+	Node      *xmltree.Node
+	Automatic bool
+}
+
+// ParentName returns the style:parent-style-name of the definition
+func (s *StyleDef) ParentName() string {
+	return s.Node.AttrValue(NSStyle, "parent-style-name")
+}
+
+// Styles indexes the style:style definitions of a document, and the font
+// faces they use, so that automatic styles can be followed up to the named
+// styles they are based on. Automatic style names are only unique within their part, so they are
+// looked up in the part that references them.
+type Styles struct {
+	common    map[StyleKey]*StyleDef
+	automatic map[string]map[StyleKey]*StyleDef
+	defaults  map[string]*xmltree.Node
+	fonts     map[string]*FontFace
+}
+
+// NewStyles indexes the styles defined in parsed styles.xml and content.xml
+// parts, named by the part names given; either part may be nil
+func NewStyles(parts map[string]*xmltree.Document) *Styles {
+	s := &Styles{
+		common:    make(map[StyleKey]*StyleDef),
+		automatic: make(map[string]map[StyleKey]*StyleDef),
+		defaults:  make(map[string]*xmltree.Node),
+		fonts:     make(map[string]*FontFace),
+	}
+	for name, part := range parts {
+		if part == nil {
+			continue
+		}
+		s.automatic[name] = make(map[StyleKey]*StyleDef)
+		for _, section := range part.Root.Elements() {
+			if section.Is(NSOffice, "font-face-decls") {
+				for _, n := range section.Elements() {
+					if n.Is(NSStyle, "font-face") {
+						s.fonts[n.AttrValue(NSStyle, "name")] = NewFontFace(n)
+					}
+				}
+				continue
+			}
+			automatic := section.Is(NSOffice, "automatic-styles")
+			if !automatic && !section.Is(NSOffice, "styles") {
+				continue
+			}
+			for _, n := range section.Elements() {
+				if n.Is(NSStyle, "default-style") {
+					s.defaults[n.AttrValue(NSStyle, "family")] = n
+					continue
+				}
+				if !n.Is(NSStyle, "style") {
+					continue
+				}
+				def := &StyleDef{
+					Key:       StyleKey{Family: n.AttrValue(NSStyle, "family"), Name: n.AttrValue(NSStyle, "name")},
+					Part:      name,
+					Node:      n,
+					Automatic: automatic,
+				}
+				if automatic {
+					s.automatic[name][def.Key] = def
+				} else {
+					s.common[def.Key] = def
+				}
+			}
+		}
+	}
+	return s
+}
+
+// Styles indexes styles.xml and content.xml of the main document (prefix
+// "") or of an embedded sub-document (prefix "Object 1/")
+func (d *Document) Styles(prefix string) (*Styles, error) {
+	parts := make(map[string]*xmltree.Document)
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		part, err := d.Part(prefix + name)
+		if err != nil {
+			return nil, err
+		}
+		parts[prefix+name] = part
+	}
+	return NewStyles(parts), nil
+}
+
+// FontFace returns the font face declared with the given style:font-name,
+// or nil
+func (s *Styles) FontFace(name string) *FontFace {
+	return s.fonts[name]
+}
+
+// Lookup finds the style a reference in the given part names, trying the
+// part's automatic styles before the common ones
+func (s *Styles) Lookup(part string, key StyleKey) *StyleDef {
+	if def, ok := s.automatic[part][key]; ok {
+		return def
+	}
+	return s.common[key]
+}
+
+// Automatic returns the automatic styles defined in a part
+func (s *Styles) Automatic(part string) []*StyleDef {
+	var defs []*StyleDef
+	for _, def := range s.automatic[part] {
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// Parent returns the style a definition inherits from, or nil. Parents are
+// always common styles.
+func (s *Styles) Parent(def *StyleDef) *StyleDef {
+	name := def.ParentName()
+	if name == "" {
+		return nil
+	}
+	parent := s.common[StyleKey{Family: def.Key.Family, Name: name}]
+	if parent == def {
+		return nil
+	}
+	return parent
+}
+
+// Named follows an automatic style to the common style it is based on;
+// a common style is returned as it is. The result is nil for an automatic
+// style with no parent.
+func (s *Styles) Named(def *StyleDef) *StyleDef {
+	if def == nil || !def.Automatic {
+		return def
+	}
+	return s.Parent(def)
+}
+
+// Property returns the effective value of a formatting property, such as
+// fo:font-weight in style:text-properties, looking up the parent chain and
+// finally at the family's default style
+func (s *Styles) Property(def *StyleDef, properties string, attr xml.Name) (string, bool) {
+	if def == nil {
+		return "", false
+	}
+	if value, ok := s.Defined(def, properties, attr); ok {
+		return value, true
+	}
+	return s.Default(def.Key.Family, properties, attr)
+}
+
+// Defined returns a formatting property set by a style or one of its
+// ancestors, without falling back to the default style
+func (s *Styles) Defined(def *StyleDef, properties string, attr xml.Name) (string, bool) {
+	seen := make(map[*StyleDef]bool)
+	for ; def != nil && !seen[def]; def = s.Parent(def) {
+		seen[def] = true
+		if value, ok := propertyOf(def.Node, properties, attr); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Default returns a formatting property from the default style of a family
+func (s *Styles) Default(family, properties string, attr xml.Name) (string, bool) {
+	return propertyOf(s.defaults[family], properties, attr)
+}
+
+// inherited returns the value a definition would get from its parent chain
+// if it didn't set the property itself
+func (s *Styles) inherited(def *StyleDef, properties string, attr xml.Name) (string, bool) {
+	if parent := s.Parent(def); parent != nil {
+		return s.Property(parent, properties, attr)
+	}
+	return propertyOf(s.defaults[def.Key.Family], properties, attr)
+}
+
+func propertyOf(n *xmltree.Node, properties string, attr xml.Name) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+	if p := n.Child(NSStyle, properties); p != nil {
+		return p.Attr(attr.Space, attr.Local)
+	}
+	return "", false
+}
+
+// IsRsid reports whether an attribute is one of the revision-tracking ids
+// LibreOffice sprinkles over automatic styles as text is edited
+func IsRsid(name xml.Name) bool {
+	return name.Space == NSOfficeOOO && (name.Local == "rsid" || name.Local == "paragraph-rsid")
+}
+
+// Redundant reports whether an automatic style adds nothing to its parent:
+// every property it sets is an rsid or the value it would inherit anyway.
+// Referring to the parent instead formats the text the same way.
+func (s *Styles) Redundant(def *StyleDef) bool {
+	if !def.Automatic || s.Parent(def) == nil {
+		return false
+	}
+	for _, attr := range def.Node.Attrs {
+		// style:master-page-name, style:list-style-name and the like matter
+		if attr.Name.Space != NSStyle || (attr.Name.Local != "name" && attr.Name.Local != "family" && attr.Name.Local != "parent-style-name") {
+			return false
+		}
+	}
+	for _, properties := range def.Node.Children {
+		if properties.Kind != xmltree.ElementNode {
+			continue
+		}
+		if properties.Name.Space != NSStyle || !isPropertiesElement(properties.Name.Local) || len(properties.Children) > 0 {
+			return false
+		}
+		for _, attr := range properties.Attrs {
+			if IsRsid(attr.Name) {
+				continue
+			}
+			if value, ok := s.inherited(def, properties.Name.Local, attr.Name); !ok || value != attr.Value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isPropertiesElement(local string) bool {
+	switch local {
+	case "text-properties", "paragraph-properties", "graphic-properties",
+		"table-properties", "table-column-properties", "table-row-properties",
+		"table-cell-properties", "section-properties", "ruby-properties":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package odf
+
+import (
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestRedundant(t *testing.T) {
+	const styles = `<office:styles>` +
+		`<style:default-style style:family="paragraph"><style:paragraph-properties fo:margin-top="0in"/></style:default-style>` +
+		`<style:style style:name="Standard" style:family="paragraph"><style:text-properties fo:font-size="12pt"/></style:style>` +
+		`<style:style style:name="Quote" style:family="paragraph" style:parent-style-name="Standard"><style:paragraph-properties fo:margin-left="1in"/></style:style>` +
+		`</office:styles>`
+
+	tests := []struct {
+		name       string
+		definition string // an automatic style in content.xml, named P1
+		want       bool
+	}{
+		{"only rsids", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"><style:paragraph-properties officeooo:paragraph-rsid="00aa"/><style:text-properties officeooo:rsid="00bb"/></style:style>`, true},
+		{"no properties", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"/>`, true},
+		{"the parent's value", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"><style:paragraph-properties fo:margin-left="1in"/></style:style>`, true},
+		{"a value inherited from further up", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"><style:text-properties fo:font-size="12pt" officeooo:rsid="00bb"/></style:style>`, true},
+		{"the default style's value", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"><style:paragraph-properties fo:margin-top="0in"/></style:style>`, true},
+		{"a different value", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"><style:paragraph-properties fo:margin-left="2in"/></style:style>`, false},
+		{"a property nothing sets", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"><style:text-properties fo:font-weight="bold"/></style:style>`, false},
+		{"a master page", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote" style:master-page-name="First"/>`, false},
+		{"a list style", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote" style:list-style-name="L1"/>`, false},
+		{"tab stops", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"><style:paragraph-properties><style:tab-stops/></style:paragraph-properties></style:style>`, false},
+		{"no parent", `<style:style style:name="P1" style:family="paragraph"><style:text-properties officeooo:rsid="00bb"/></style:style>`, false},
+		{"an unknown parent", `<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Nowhere"/>`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStyles(map[string]*xmltree.Document{
+				"styles.xml":  parseTestPart(t, styles),
+				"content.xml": parseTestPart(t, `<office:automatic-styles>`+tt.definition+`</office:automatic-styles>`),
+			})
+			def := s.Lookup("content.xml", StyleKey{Family: "paragraph", Name: "P1"})
+			if def == nil {
+				t.Fatal("P1 not found")
+			}
+			if got := s.Redundant(def); got != tt.want {
+				t.Errorf("Redundant = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Common styles are never redundant
+	s := NewStyles(map[string]*xmltree.Document{"styles.xml": parseTestPart(t, styles)})
+	if s.Redundant(s.Lookup("styles.xml", StyleKey{Family: "paragraph", Name: "Quote"})) {
+		t.Errorf("common style Quote is redundant")
+	}
+}
@@ -0,0 +1,174 @@
+package odf
+
+import (
+	"sort"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+// StyleNode is a style definition in the reference graph. Automatic styles
+// and font faces are only unique within their part, so a node names the
+// part the definition is in as well as the style.
+type StyleNode struct {
+	Part string
+	Key  StyleKey
+}
+
+// StyleGraph records which style definitions of a document refer to which,
+// through parent and next styles, list styles, conditional style maps,
+// master pages and the rest of the style attribute registry, and which
+// styles the document itself uses
+type StyleGraph struct {
+	Definitions map[StyleNode]*xmltree.Node
+	Edges       map[StyleNode][]StyleNode
+	Roots       []StyleNode // used by the text, or by settings such as notes configuration
+}
+
+// StyleGraph builds the reference graph over styles.xml and content.xml of
+// the main document (prefix "") or of an embedded sub-document
+func (d *Document) StyleGraph(prefix string) (*StyleGraph, error) {
+	g := &StyleGraph{
+		Definitions: make(map[StyleNode]*xmltree.Node),
+		Edges:       make(map[StyleNode][]StyleNode),
+	}
+
+	type reference struct {
+		from *StyleNode
+		part string
+		key  StyleKey
+	}
+	var refs []reference
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		part, err := d.Part(prefix + name)
+		if err != nil {
+			return nil, err
+		}
+		if part == nil {
+			continue
+		}
+		for _, section := range part.Root.Elements() {
+			for _, n := range section.Elements() {
+				var from *StyleNode
+				if key, ok := DefinitionKey(n); ok && isDefinitionSection(section) {
+					from = &StyleNode{Part: prefix + name, Key: key}
+					g.Definitions[*from] = n
+				}
+				for _, key := range References(n) {
+					refs = append(refs, reference{from: from, part: prefix + name, key: key})
+				}
+			}
+		}
+	}
+
+	// Automatic styles are looked up in the part that refers to them, and
+	// common styles in styles.xml
+	for _, ref := range refs {
+		to := StyleNode{Part: ref.part, Key: ref.key}
+		if g.Definitions[to] == nil {
+			to.Part = prefix + "styles.xml"
+		}
+		if g.Definitions[to] == nil {
+			continue
+		}
+		if ref.from == nil {
+			g.Roots = append(g.Roots, to)
+		} else {
+			g.Edges[*ref.from] = append(g.Edges[*ref.from], to)
+		}
+	}
+	return g, nil
+}
+
+func isDefinitionSection(section *xmltree.Node) bool {
+	for _, local := range definitionSections {
+		if section.Is(NSOffice, local) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reachable returns the definitions reachable from the roots and from the
+// extra nodes given
+func (g *StyleGraph) Reachable(extra []StyleNode) map[StyleNode]bool {
+	reached := make(map[StyleNode]bool)
+	var visit func(StyleNode)
+	visit = func(node StyleNode) {
+		if reached[node] {
+			return
+		}
+		reached[node] = true
+		for _, to := range g.Edges[node] {
+			visit(to)
+		}
+	}
+	for _, node := range g.Roots {
+		visit(node)
+	}
+	for _, node := range extra {
+		visit(node)
+	}
+	return reached
+}
+
+// RemoveUnusedStyles deletes the automatic styles nothing refers to, and if
+// common is set the unused paragraph, text, list, table, table cell,
+// section and graphic (frame) styles of office:styles whose names or
+// display names aren't in keep. A style that is only used by another unused
+// style goes too. Master pages, font faces, default styles and the outline
+// style are always kept. It returns what it removed.
+func (d *Document) RemoveUnusedStyles(prefix string, common bool, keep []string) ([]StyleNode, error) {
+	g, err := d.StyleGraph(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make(map[string]bool)
+	for _, name := range keep {
+		kept[name] = true
+	}
+	var pinned []StyleNode
+	for node, n := range g.Definitions {
+		if !removable(n, common) || kept[node.Key.Name] || kept[n.AttrValue(NSStyle, "display-name")] {
+			pinned = append(pinned, node)
+		}
+	}
+
+	reached := g.Reachable(pinned)
+	var removed []StyleNode
+	for node, n := range g.Definitions {
+		if !reached[node] {
+			n.Remove()
+			removed = append(removed, node)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		if removed[i].Part != removed[j].Part {
+			return removed[i].Part < removed[j].Part
+		}
+		if removed[i].Key.Family != removed[j].Key.Family {
+			return removed[i].Key.Family < removed[j].Key.Family
+		}
+		return removed[i].Key.Name < removed[j].Key.Name
+	})
+	return removed, nil
+}
+
+// removable reports whether an unused definition may be deleted: any
+// automatic style, and with common set the common styles authors apply
+func removable(n *xmltree.Node, common bool) bool {
+	switch {
+	case n.Parent.Is(NSOffice, "automatic-styles"):
+		return true
+	case !common || !n.Parent.Is(NSOffice, "styles"):
+		return false
+	case n.Is(NSText, "list-style"):
+		return true
+	case n.Is(NSStyle, "style"):
+		switch n.AttrValue(NSStyle, "family") {
+		case "paragraph", "text", "table", "table-cell", "section", "graphic":
+			return true
+		}
+	}
+	return false
+}
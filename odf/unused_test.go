@@ -0,0 +1,127 @@
+package odf
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"LibreOfficeReformatter/xmltree"
+)
+
+func TestRemoveUnusedStyles(t *testing.T) {
+	const styles = `<office:font-face-decls><style:font-face style:name="Unused Font" svg:font-family="X"/></office:font-face-decls>` +
+		`<office:styles>` +
+		`<style:style style:name="Standard" style:family="paragraph"/>` +
+		`<style:style style:name="Body" style:family="paragraph" style:parent-style-name="Standard" style:next-style-name="After"/>` +
+		`<style:style style:name="After" style:family="paragraph"/>` +
+		`<style:style style:name="Quote" style:family="paragraph"/>` +
+		`<style:style style:name="Orphan" style:family="paragraph" style:display-name="Orphan Style"/>` +
+		`<style:style style:name="Emphasis" style:family="text"/>` +
+		`<text:list-style style:name="Numbers"/>` +
+		`<style:style style:name="Frame" style:family="graphic" style:parent-style-name="Graphics"/>` +
+		`<style:style style:name="Graphics" style:family="graphic"/>` +
+		`<style:style style:name="Grid" style:family="table"/>` +
+		`<style:style style:name="GridCell" style:family="table-cell"/>` +
+		`<style:style style:name="Sidebar" style:family="section"/>` +
+		`<style:style style:name="Chart" style:family="chart"/>` +
+		`</office:styles>` +
+		`<office:automatic-styles>` +
+		`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Quote"/>` +
+		`<style:page-layout style:name="pm1"/>` +
+		`<style:page-layout style:name="pm2"/>` +
+		`</office:automatic-styles>` +
+		`<office:master-styles><style:master-page style:name="Standard" style:page-layout-name="pm1"/></office:master-styles>`
+	const content = `<office:automatic-styles>` +
+		`<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Body"/>` +
+		`<style:style style:name="P2" style:family="paragraph" style:parent-style-name="Quote"/>` +
+		`<style:style style:name="T1" style:family="text" style:parent-style-name="Emphasis"/>` +
+		`</office:automatic-styles>` +
+		`<office:body><office:text><text:p text:style-name="P1">used</text:p></office:text></office:body>`
+
+	node := func(part, family, name string) StyleNode {
+		return StyleNode{Part: part, Key: StyleKey{Family: family, Name: name}}
+	}
+	automatic := []StyleNode{
+		node("content.xml", "paragraph", "P2"),
+		node("content.xml", "text", "T1"),
+		node("styles.xml", "page-layout", "pm2"),
+		// P1 in content.xml is used; the one in styles.xml isn't
+		node("styles.xml", "paragraph", "P1"),
+	}
+	tests := []struct {
+		name   string
+		common bool
+		keep   []string
+		want   []StyleNode
+	}{
+		{"automatic styles only", false, nil, automatic},
+		{
+			"common styles of every removable family, and those only unused styles use",
+			true, nil,
+			append(append([]StyleNode{}, automatic...),
+				node("styles.xml", "graphic", "Frame"),
+				node("styles.xml", "graphic", "Graphics"),
+				node("styles.xml", "list", "Numbers"),
+				node("styles.xml", "paragraph", "Orphan"),
+				node("styles.xml", "paragraph", "Quote"),
+				node("styles.xml", "section", "Sidebar"),
+				node("styles.xml", "table", "Grid"),
+				node("styles.xml", "table-cell", "GridCell"),
+				node("styles.xml", "text", "Emphasis"),
+			),
+		},
+		{
+			"kept by name or display name, with what they use",
+			true, []string{"Orphan Style", "Frame", "Numbers", "Grid", "GridCell", "Sidebar"},
+			append(append([]StyleNode{}, automatic...),
+				node("styles.xml", "paragraph", "Quote"),
+				node("styles.xml", "text", "Emphasis"),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &Document{Package: New(), parts: map[string]*xmltree.Document{
+				"styles.xml":  parseTestPart(t, styles),
+				"content.xml": parseTestPart(t, content),
+			}}
+			removed, err := doc.RemoveUnusedStyles("", tt.common, tt.keep)
+			if err != nil {
+				t.Fatalf("RemoveUnusedStyles: %v", err)
+			}
+			want := append([]StyleNode{}, tt.want...)
+			sortStyleNodes(want)
+			if !reflect.DeepEqual(removed, want) {
+				t.Errorf("removed %v\nwant    %v", removed, want)
+			}
+
+			// What was removed is gone, and what is left is still reachable
+			g, err := doc.StyleGraph("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range removed {
+				if g.Definitions[n] != nil {
+					t.Errorf("%v is still defined", n)
+				}
+			}
+			if again, _ := doc.RemoveUnusedStyles("", tt.common, tt.keep); len(again) != 0 {
+				t.Errorf("a second pass removed %v", again)
+			}
+		})
+	}
+}
+
+// sortStyleNodes puts nodes in the order RemoveUnusedStyles reports them
+func sortStyleNodes(nodes []StyleNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Part != nodes[j].Part {
+			return nodes[i].Part < nodes[j].Part
+		}
+		if nodes[i].Key.Family != nodes[j].Key.Family {
+			return nodes[i].Key.Family < nodes[j].Key.Family
+		}
+		return nodes[i].Key.Name < nodes[j].Key.Name
+	})
+}
@@ -0,0 +1,374 @@
+package xmltree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// xmlNamespace is bound to the "xml" prefix without being declared
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// binding is one namespace declaration
+type binding struct {
+	prefix, uri string
+}
+
+// scope holds the prefixes declared on an element, chained to those of its
+// ancestors; the default namespace has the empty prefix
+type scope struct {
+	parent   *scope
+	bindings []binding
+}
+
+func (s *scope) uri(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for ; s != nil; s = s.parent {
+		for _, b := range s.bindings {
+			if b.prefix == prefix {
+				return b.uri, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (s *scope) prefix(uri string) (string, bool) {
+	if uri == xmlNamespace {
+		return "xml", true
+	}
+	for inner := s; inner != nil; inner = inner.parent {
+		for _, b := range inner.bindings {
+			// A prefix only counts if nothing nearer has rebound it
+			if found, _ := s.uri(b.prefix); b.uri == uri && found == uri {
+				return b.prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// declarations returns the scope opened by an element's xmlns attributes
+func declarations(parent *scope, attrs []Attr) *scope {
+	var bindings []binding
+	for _, a := range attrs {
+		if prefix, ok := a.declares(); ok {
+			bindings = append(bindings, binding{prefix, a.Value})
+		}
+	}
+	if bindings == nil {
+		return parent
+	}
+	return &scope{parent: parent, bindings: bindings}
+}
+
+// Parse reads an XML part into a Document. Names are resolved to their
+// namespace URIs, while the original prefixes and namespace declarations are
+// kept so that the part can be written back exactly as it was.
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := parse(data, nil, func(n *Node) {
+		switch {
+		case n.Kind == ElementNode:
+			doc.Root = n
+		case doc.Root == nil:
+			doc.Prolog = append(doc.Prolog, n)
+		default:
+			doc.Epilog = append(doc.Epilog, n)
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	if doc.Root == nil {
+		return nil, fmt.Errorf("failed to parse XML: no root element")
+	}
+	return doc, nil
+}
+
+// ParseFragment parses a run of mixed content, such as the inner XML of an
+// element, using the namespace declarations in scope at context
+func ParseFragment(context *Node, data []byte) ([]*Node, error) {
+	var nodes []*Node
+	err := parse(data, scopeOf(context), func(n *Node) {
+		nodes = append(nodes, n)
+	})
+	return nodes, err
+}
+
+// scopeOf collects the namespace declarations in scope at n
+func scopeOf(n *Node) *scope {
+	if n == nil {
+		return nil
+	}
+	return declarations(scopeOf(n.Parent), n.Attrs)
+}
+
+// parse decodes data, passing each top-level node to emit
+func parse(data []byte, outer *scope, emit func(*Node)) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var current *Node
+	var scopes []*scope
+	inner := outer
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse XML: %w", err)
+		}
+
+		var node *Node
+		switch t := token.(type) {
+		case xml.StartElement:
+			node = &Node{Kind: ElementNode, Prefix: t.Name.Space}
+			for _, a := range t.Attr {
+				node.Attrs = append(node.Attrs, Attr{Name: a.Name, Prefix: a.Name.Space, Value: a.Value})
+			}
+			scopes = append(scopes, inner)
+			inner = declarations(inner, node.Attrs)
+			if node.Name, err = resolve(inner, t.Name, true); err != nil {
+				return err
+			}
+			for i := range node.Attrs {
+				if node.Attrs[i].Name, err = resolve(inner, node.Attrs[i].Name, false); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.Prefix || t.Name.Local != current.Name.Local {
+				return fmt.Errorf("failed to parse XML: unexpected end element </%s>", qualified(t.Name.Space, t.Name.Local))
+			}
+			inner = scopes[len(scopes)-1]
+			scopes = scopes[:len(scopes)-1]
+			current = current.Parent
+			continue
+		case xml.CharData:
+			node = &Node{Kind: TextNode, Data: string(t)}
+		case xml.Comment:
+			node = &Node{Kind: CommentNode, Data: string(t)}
+		case xml.ProcInst:
+			node = &Node{Kind: ProcInstNode, Name: xml.Name{Local: t.Target}, Data: string(t.Inst)}
+		case xml.Directive:
+			node = &Node{Kind: DirectiveNode, Data: string(t)}
+		}
+
+		if current != nil {
+			current.AppendChild(node)
+		} else {
+			emit(node)
+		}
+		if node.Kind == ElementNode {
+			current = node
+		}
+	}
+
+	if current != nil {
+		return fmt.Errorf("failed to parse XML: unclosed element <%s>", current.QName())
+	}
+	return nil
+}
+
+// resolve turns a raw prefix:local name into a namespace URI and local name.
+// Unprefixed attributes are in no namespace; namespace declarations keep
+// "xmlns" as their space.
+func resolve(s *scope, raw xml.Name, element bool) (xml.Name, error) {
+	switch {
+	case !element && raw.Space == "" && raw.Local == "xmlns":
+		return raw, nil
+	case !element && raw.Space == "xmlns":
+		return raw, nil
+	case !element && raw.Space == "":
+		return raw, nil
+	}
+	uri, ok := s.uri(raw.Space)
+	if !ok && raw.Space != "" {
+		return raw, fmt.Errorf("failed to parse XML: undeclared namespace prefix %q", raw.Space)
+	}
+	return xml.Name{Space: uri, Local: raw.Local}, nil
+}
+
+// Bytes serialises the document
+func (d *Document) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	if err := d.Write(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Write serialises the document to w without reformatting it, since
+// whitespace inside ODF paragraphs is content. Elements and attributes are
+// written with the prefixes they were parsed with, and namespace
+// declarations exactly as they were; new nodes borrow the prefix already
+// declared for their namespace.
+func (d *Document) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, n := range d.Prolog {
+		if err := writeNode(bw, n, nil); err != nil {
+			return err
+		}
+	}
+	if err := writeNode(bw, d.Root, nil); err != nil {
+		return err
+	}
+	for _, n := range d.Epilog {
+		if err := writeNode(bw, n, nil); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write XML: %w", err)
+	}
+	return nil
+}
+
+// String serialises a single node and everything below it, using the
+// namespace declarations in scope where it sits in its tree
+func (n *Node) String() string {
+	var b bytes.Buffer
+	bw := bufio.NewWriter(&b)
+	if err := writeNode(bw, n, scopeOf(n.Parent)); err != nil {
+		return err.Error()
+	}
+	bw.Flush()
+	return b.String()
+}
+
+func writeNode(w *bufio.Writer, n *Node, outer *scope) error {
+	switch n.Kind {
+	case ElementNode:
+		return writeElement(w, n, outer)
+	case TextNode:
+		escape(w, n.Data, false)
+	case CommentNode:
+		w.WriteString("<!--" + n.Data + "-->")
+	case ProcInstNode:
+		w.WriteString("<?" + n.Name.Local)
+		if n.Data != "" {
+			w.WriteString(" " + n.Data)
+		}
+		w.WriteString("?>")
+	case DirectiveNode:
+		w.WriteString("<!" + n.Data + ">")
+	}
+	return nil
+}
+
+func writeElement(w *bufio.Writer, n *Node, outer *scope) error {
+	inner := declarations(outer, n.Attrs)
+
+	// Nodes built in code have no prefix yet, so borrow the declared one,
+	// or declare one on this element as a last resort
+	var extra []Attr
+	name, err := prefixFor(inner, n.Name.Space, n.Prefix, true)
+	if err != nil {
+		inner, extra = declare(inner, n.Name.Space, extra)
+		name, _ = prefixFor(inner, n.Name.Space, "", true)
+	}
+	w.WriteString("<" + qualified(name, n.Name.Local))
+
+	for _, a := range n.Attrs {
+		if err := writeAttr(w, inner, a, &extra); err != nil {
+			return err
+		}
+	}
+	for _, a := range extra {
+		writeQualifiedAttr(w, qualified("xmlns", a.Name.Local), a.Value)
+	}
+
+	if len(n.Children) == 0 {
+		w.WriteString("/>")
+		return nil
+	}
+	w.WriteString(">")
+	for _, c := range n.Children {
+		if err := writeNode(w, c, inner); err != nil {
+			return err
+		}
+	}
+	w.WriteString("</" + qualified(name, n.Name.Local) + ">")
+	return nil
+}
+
+func writeAttr(w *bufio.Writer, s *scope, a Attr, extra *[]Attr) error {
+	if _, ok := a.declares(); ok || a.Name.Space == "" {
+		writeQualifiedAttr(w, qualified(a.Name.Space, a.Name.Local), a.Value)
+		return nil
+	}
+	prefix, err := prefixFor(s, a.Name.Space, a.Prefix, false)
+	if err != nil {
+		// The element's scope is already fixed, so note the new declaration
+		// and write it after the attributes
+		for _, e := range *extra {
+			if e.Value == a.Name.Space {
+				prefix = e.Name.Local
+			}
+		}
+		if prefix == "" {
+			prefix = fmt.Sprintf("ns%d", len(*extra)+1)
+			*extra = append(*extra, Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: a.Name.Space})
+		}
+	}
+	writeQualifiedAttr(w, qualified(prefix, a.Name.Local), a.Value)
+	return nil
+}
+
+func writeQualifiedAttr(w *bufio.Writer, name, value string) {
+	w.WriteString(" " + name + `="`)
+	escape(w, value, true)
+	w.WriteString(`"`)
+}
+
+// prefixFor picks the prefix to write for a namespace: the original one if
+// it still maps to that namespace, otherwise any declared for it
+func prefixFor(s *scope, uri, original string, element bool) (string, error) {
+	if uri == "" {
+		return "", nil
+	}
+	if original != "" || element {
+		if found, ok := s.uri(original); ok && found == uri {
+			return original, nil
+		}
+	}
+	if prefix, ok := s.prefix(uri); ok && (prefix != "" || element) {
+		return prefix, nil
+	}
+	return "", fmt.Errorf("namespace %s is not declared", uri)
+}
+
+// declare adds a generated prefix for uri to the element being written
+func declare(s *scope, uri string, extra []Attr) (*scope, []Attr) {
+	prefix := fmt.Sprintf("ns%d", len(extra)+1)
+	decl := Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: uri}
+	return declarations(s, []Attr{decl}), append(extra, decl)
+}
+
+func qualified(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+// The escapers write character data the way LibreOffice does, escaping
+// quotes in text as well as in attributes, and whitespace in attributes
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;", "\r", "&#13;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;", "\r", "&#13;", "\n", "&#10;", "\t", "&#9;")
+)
+
+func escape(w *bufio.Writer, s string, attr bool) {
+	if attr {
+		attrEscaper.WriteString(w, s)
+	} else {
+		textEscaper.WriteString(w, s)
+	}
+}
@@ -0,0 +1,161 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+	}{
+		{"empty root", `<root/>`},
+		{"declaration and epilog", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<root/>\n<!-- after -->"},
+		{"mixed content", `<p>Some <span>bold</span> text <b>and</b> more</p>`},
+		{"whitespace is content", "<p>  two  spaces\n\tand a tab </p>"},
+		{"empty elements", `<root><a/><b x="1"/></root>`},
+		{"comments and processing instructions", `<root><!-- a comment --><?target some data?><?bare?></root>`},
+		{"doctype", `<!DOCTYPE root><root/>`},
+		{"escaped text", `<p>a &lt; b &amp;&amp; c &gt; d, &quot;quoted&quot; &apos;too&apos;</p>`},
+		{"escaped attributes", `<p title="line&#10;break&#9;tab &quot;q&quot; &lt;&amp;&gt;"/>`},
+		{"carriage return", `<p a="x&#13;y">x&#13;y</p>`},
+		{"attribute order", `<p z="1" a="2" m="3"/>`},
+		{"unicode", `<p>naïve — “quotes” ①</p>`},
+		{
+			"odf namespaces",
+			`<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" office:version="1.3">` +
+				`<office:body><office:text><text:p text:style-name="P1">Hello <text:span text:style-name="T1">world</text:span><text:s text:c="3"/>!</text:p></office:text></office:body>` +
+				`</office:document-content>`,
+		},
+		{"default namespace", `<root xmlns="urn:a"><child attr="x"/></root>`},
+		{"xml prefix needs no declaration", `<p xml:id="p1" xml:lang="en">x</p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.xml))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			out, err := doc.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes: %v", err)
+			}
+			if string(out) != tt.xml {
+				t.Errorf("round trip changed the XML\n got: %s\nwant: %s", out, tt.xml)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+	}{
+		{"no root", `<?xml version="1.0"?>`},
+		{"unclosed", `<root><a>`},
+		{"mismatched", `<root></a>`},
+		{"undeclared prefix", `<text:p/>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.xml)); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.xml)
+			}
+		})
+	}
+}
+
+func TestWriteNewNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		// add builds new nodes onto the parsed root
+		add  func(root *Node)
+		want string
+	}{
+		{
+			"borrows the declared prefix",
+			`<r xmlns:t="urn:t"/>`,
+			func(root *Node) {
+				e := NewElement(xml.Name{Space: "urn:t", Local: "p"})
+				e.SetAttr("urn:t", "a", "1")
+				root.AppendChild(e)
+			},
+			`<r xmlns:t="urn:t"><t:p t:a="1"/></r>`,
+		},
+		{
+			"borrows whatever prefix the document binds",
+			`<x:r xmlns:x="urn:t"/>`,
+			func(root *Node) {
+				root.AppendChild(NewElement(xml.Name{Space: "urn:t", Local: "p"}))
+			},
+			`<x:r xmlns:x="urn:t"><x:p/></x:r>`,
+		},
+		{
+			"uses the default namespace for elements",
+			`<r xmlns="urn:d"/>`,
+			func(root *Node) {
+				root.AppendChild(NewElement(xml.Name{Space: "urn:d", Local: "p"}))
+			},
+			`<r xmlns="urn:d"><p/></r>`,
+		},
+		{
+			"declares an undeclared namespace",
+			`<r/>`,
+			func(root *Node) {
+				root.AppendChild(NewElement(xml.Name{Space: "urn:x", Local: "e"}))
+			},
+			`<r><ns1:e xmlns:ns1="urn:x"/></r>`,
+		},
+		{
+			"declares a namespace for an attribute",
+			`<r xmlns:t="urn:t"/>`,
+			func(root *Node) {
+				root.SetAttr("urn:y", "k", "v")
+			},
+			`<r xmlns:t="urn:t" ns1:k="v" xmlns:ns1="urn:y"/>`,
+		},
+		{
+			"does not use a prefix rebound further in",
+			`<r xmlns:a="urn:1"><s xmlns:a="urn:2"/></r>`,
+			func(root *Node) {
+				root.Elements()[0].AppendChild(NewElement(xml.Name{Space: "urn:1", Local: "e"}))
+			},
+			`<r xmlns:a="urn:1"><s xmlns:a="urn:2"><ns1:e xmlns:ns1="urn:1"/></s></r>`,
+		},
+		{
+			"keeps the prefix of a moved element",
+			`<r xmlns:a="urn:1" xmlns:b="urn:1"><b:e/><f/></r>`,
+			func(root *Node) {
+				moved := root.Elements()[0]
+				moved.Remove()
+				root.Elements()[0].AppendChild(moved)
+			},
+			`<r xmlns:a="urn:1" xmlns:b="urn:1"><f><b:e/></f></r>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.xml))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			tt.add(doc.Root)
+			out, err := doc.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes: %v", err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got  %s\nwant %s", out, tt.want)
+			}
+			if _, err := Parse(out); err != nil {
+				t.Errorf("output doesn't parse: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// Package xmltree is a lossless XML tree for ODF parts. Unlike decoding into
+// structs, it keeps text, elements, comments and processing instructions
+// interleaved in document order, so mixed content such as a text:p holding
+// both text and text:span elements survives a round trip.
+package xmltree
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Kind identifies what sort of node a Node is
+type Kind int
+
+const (
+	ElementNode Kind = iota
+	TextNode
+	CommentNode
+	ProcInstNode
+	DirectiveNode
+)
+
+// Attr is an attribute. Name.Space is the namespace URI and Prefix the
+// prefix it was written with; namespace declarations have "xmlns" as their
+// space, or are named "xmlns" for the default namespace.
+type Attr struct {
+	Name   xml.Name
+	Prefix string
+	Value  string
+}
+
+// declares reports whether the attribute is a namespace declaration, and
+// the prefix it declares
+func (a Attr) declares() (string, bool) {
+	switch {
+	case a.Name.Space == "xmlns":
+		return a.Name.Local, true
+	case a.Name.Space == "" && a.Name.Local == "xmlns":
+		return "", true
+	}
+	return "", false
+}
+
+// Node is an element, a run of character data, a comment, a processing
+// instruction or a directive. Only elements have a name, attributes and
+// children; the others keep their content in Data (for a processing
+// instruction, Name.Local is the target and Data the instruction).
+// Element names are resolved to namespace URIs, with the original prefix
+// kept alongside so that the element is written back the way it was read.
+type Node struct {
+	Kind     Kind
+	Name     xml.Name
+	Prefix   string
+	Attrs    []Attr
+	Children []*Node
+	Data     string
+	Parent   *Node
+}
+
+// Document is a parsed XML part: the root element plus anything around it,
+// such as the XML declaration
+type Document struct {
+	Prolog []*Node
+	Root   *Node
+	Epilog []*Node
+}
+
+// NewElement returns an element with no attributes or children
+func NewElement(name xml.Name) *Node {
+	return &Node{Kind: ElementNode, Name: name}
+}
+
+// NewText returns a character data node
+func NewText(text string) *Node {
+	return &Node{Kind: TextNode, Data: text}
+}
+
+// QName returns the element's name as written, prefix and all
+func (n *Node) QName() string {
+	return qualified(n.Prefix, n.Name.Local)
+}
+
+// Is reports whether n is an element with the given namespace and local name
+func (n *Node) Is(space, local string) bool {
+	return n.Kind == ElementNode && n.Name.Space == space && n.Name.Local == local
+}
+
+// Attr returns the value of an attribute, and whether it was present
+func (n *Node) Attr(space, local string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// AttrValue returns the value of an attribute, or "" if it isn't present
+func (n *Node) AttrValue(space, local string) string {
+	value, _ := n.Attr(space, local)
+	return value
+}
+
+// SetAttr sets an attribute, adding it after the existing ones if needed
+func (n *Node) SetAttr(space, local, value string) {
+	for i, a := range n.Attrs {
+		if a.Name.Space == space && a.Name.Local == local {
+			n.Attrs[i].Value = value
+			return
+		}
+	}
+	n.Attrs = append(n.Attrs, Attr{Name: xml.Name{Space: space, Local: local}, Value: value})
+}
+
+// RemoveAttr deletes an attribute, reporting whether it was present
+func (n *Node) RemoveAttr(space, local string) bool {
+	for i, a := range n.Attrs {
+		if a.Name.Space == space && a.Name.Local == local {
+			n.Attrs = append(n.Attrs[:i], n.Attrs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Elements returns the element children of n, skipping text and comments
+func (n *Node) Elements() []*Node {
+	var elements []*Node
+	for _, c := range n.Children {
+		if c.Kind == ElementNode {
+			elements = append(elements, c)
+		}
+	}
+	return elements
+}
+
+// Child returns the first child element with the given name, or nil
+func (n *Node) Child(space, local string) *Node {
+	for _, c := range n.Children {
+		if c.Is(space, local) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for n and every node below it in document order. If fn
+// returns false, the children of that node are skipped.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	// Take a copy, so fn may restructure the children it has already visited
+	children := append([]*Node(nil), n.Children...)
+	for _, c := range children {
+		c.Walk(fn)
+	}
+}
+
+// Text returns the concatenated character data below n
+func (n *Node) Text() string {
+	var b strings.Builder
+	n.Walk(func(c *Node) bool {
+		if c.Kind == TextNode {
+			b.WriteString(c.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+// AppendChild adds c as the last child of n
+func (n *Node) AppendChild(c *Node) {
+	c.Parent = n
+	n.Children = append(n.Children, c)
+}
+
+// InsertAfter adds c as the next sibling of n, which must have a parent
+func (n *Node) InsertAfter(c *Node) {
+	i := n.Index()
+	c.Parent = n.Parent
+	siblings := n.Parent.Children
+	n.Parent.Children = append(append(append([]*Node(nil), siblings[:i+1]...), c), siblings[i+1:]...)
+}
+
+// Index returns the position of n among its parent's children, or -1
+func (n *Node) Index() int {
+	if n.Parent == nil {
+		return -1
+	}
+	for i, c := range n.Parent.Children {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// Remove detaches n from its parent
+func (n *Node) Remove() {
+	if i := n.Index(); i >= 0 {
+		n.Parent.Children = append(n.Parent.Children[:i], n.Parent.Children[i+1:]...)
+	}
+	n.Parent = nil
+}
+
+// Unwrap replaces n with its children
+func (n *Node) Unwrap() {
+	i := n.Index()
+	if i < 0 {
+		return
+	}
+	parent := n.Parent
+	for _, c := range n.Children {
+		c.Parent = parent
+	}
+	children := append(append(append([]*Node(nil), parent.Children[:i]...), n.Children...), parent.Children[i+1:]...)
+	parent.Children = children
+	n.Children = nil
+	n.Parent = nil
+}
+
+// Normalize joins adjacent runs of character data below n and drops empty
+// ones, as can be left after unwrapping or removing elements
+func (n *Node) Normalize() {
+	var children []*Node
+	for _, c := range n.Children {
+		if c.Kind == TextNode {
+			if c.Data == "" {
+				continue
+			}
+			if last := len(children) - 1; last >= 0 && children[last].Kind == TextNode {
+				children[last].Data += c.Data
+				continue
+			}
+		}
+		if c.Kind == ElementNode {
+			c.Normalize()
+		}
+		children = append(children, c)
+	}
+	n.Children = children
+}
+
+// Clone returns a deep copy of n with no parent, for moving content from
+// one tree to another
+func (n *Node) Clone() *Node {
+	c := *n
+	c.Parent = nil
+	c.Attrs = append([]Attr(nil), n.Attrs...)
+	c.Children = nil
+	for _, child := range n.Children {
+		c.AppendChild(child.Clone())
+	}
+	return &c
+}